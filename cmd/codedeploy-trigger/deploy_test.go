@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mockCodeDeployClient implements the calls needed to create a deployment while other ones are active, and fails the other ones.
+type mockCodeDeployClient struct {
+	// activeDeploymentIDs become active one after another, e.g. a deployment and its rollback.
+	activeDeploymentIDs []string
+	// rejectedCreates is the number of CreateDeployment calls which are rejected even if no deployment is listed as active.
+	rejectedCreates     int
+	activeAppSpecSha256 string
+	deployments         []types.DeploymentInfo
+	createCalls         int
+	stopInputs          []*codedeploy.StopDeploymentInput
+	registerInput       *codedeploy.RegisterApplicationRevisionInput
+}
+
+func (m *mockCodeDeployClient) CreateDeployment(_ context.Context, _ *codedeploy.CreateDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.CreateDeploymentOutput, error) {
+	m.createCalls++
+	if len(m.activeDeploymentIDs) > 0 || m.createCalls <= m.rejectedCreates {
+		return nil, &types.DeploymentLimitExceededException{Message: aws.String("mock")}
+	}
+	return &codedeploy.CreateDeploymentOutput{DeploymentId: aws.String("d-new")}, nil
+}
+
+func (m *mockCodeDeployClient) ListDeployments(_ context.Context, _ *codedeploy.ListDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentsOutput, error) {
+	if len(m.activeDeploymentIDs) == 0 {
+		return &codedeploy.ListDeploymentsOutput{}, nil
+	}
+	return &codedeploy.ListDeploymentsOutput{Deployments: m.activeDeploymentIDs[:1]}, nil
+}
+
+// GetDeployment reports the active deployment as finished, so that the next one becomes active.
+func (m *mockCodeDeployClient) GetDeployment(_ context.Context, _ *codedeploy.GetDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.GetDeploymentOutput, error) {
+	info := &types.DeploymentInfo{
+		Status:   types.DeploymentStatusStopped,
+		Revision: &types.RevisionLocation{AppSpecContent: &types.AppSpecContent{Sha256: aws.String(m.activeAppSpecSha256)}},
+	}
+	if len(m.activeDeploymentIDs) > 0 {
+		m.activeDeploymentIDs = m.activeDeploymentIDs[1:]
+	}
+	return &codedeploy.GetDeploymentOutput{DeploymentInfo: info}, nil
+}
+
+func (m *mockCodeDeployClient) BatchGetDeployments(_ context.Context, _ *codedeploy.BatchGetDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentsOutput, error) {
+	return &codedeploy.BatchGetDeploymentsOutput{DeploymentsInfo: m.deployments}, nil
+}
+
+func (m *mockCodeDeployClient) StopDeployment(_ context.Context, params *codedeploy.StopDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.StopDeploymentOutput, error) {
+	m.stopInputs = append(m.stopInputs, params)
+	return &codedeploy.StopDeploymentOutput{}, nil
+}
+
+func (m *mockCodeDeployClient) RegisterApplicationRevision(_ context.Context, params *codedeploy.RegisterApplicationRevisionInput, _ ...func(*codedeploy.Options)) (*codedeploy.RegisterApplicationRevisionOutput, error) {
+	m.registerInput = params
+	return &codedeploy.RegisterApplicationRevisionOutput{}, nil
+}
+
+func (m *mockCodeDeployClient) ListDeploymentTargets(_ context.Context, _ *codedeploy.ListDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentTargetsOutput, error) {
+	return nil, errors.New("unexpected ListDeploymentTargets call")
+}
+
+func (m *mockCodeDeployClient) BatchGetDeploymentTargets(_ context.Context, _ *codedeploy.BatchGetDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentTargetsOutput, error) {
+	return nil, errors.New("unexpected BatchGetDeploymentTargets call")
+}
+
+func (m *mockCodeDeployClient) ContinueDeployment(_ context.Context, _ *codedeploy.ContinueDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.ContinueDeploymentOutput, error) {
+	return nil, errors.New("unexpected ContinueDeployment call")
+}
+
+// mockS3Client accepts every upload, records its body and returns a quoted ETag like S3.
+type mockS3Client struct {
+	putInput *s3.PutObjectInput
+	body     []byte
+}
+
+func (m *mockS3Client) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.putInput = params
+	m.body = body
+	return &s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil
+}
+
+func TestFlagContext_createDeployment(t *testing.T) {
+	appSpecCodeDeployContext, _ := (&deploy.CodeDeployContext{}).WithAppSpec(deploy.NewLambda("function-name", "function-alias", "42", "43"))
+
+	tests := []struct {
+		name                string
+		whenActive          string
+		maxWaitDuration     string
+		activeDeploymentIDs []string
+		rejectedCreates     int
+		activeAppSpecSha256 string
+		wantDeploymentID    string
+		wantCreateCalls     int
+		wantStopCalls       int
+		wantErr             bool
+	}{
+		{
+			name:                "fail",
+			whenActive:          FailPolicy,
+			activeDeploymentIDs: []string{"d-active"},
+			wantCreateCalls:     1,
+			wantErr:             true,
+		},
+		{
+			name:                "wait",
+			whenActive:          WaitPolicy,
+			activeDeploymentIDs: []string{"d-active"},
+			wantDeploymentID:    "d-new",
+			wantCreateCalls:     2,
+		},
+		{
+			name:                "wait for rollback",
+			whenActive:          WaitPolicy,
+			activeDeploymentIDs: []string{"d-active", "d-rollback"},
+			wantDeploymentID:    "d-new",
+			wantCreateCalls:     3,
+		},
+		{
+			name:                "stop",
+			whenActive:          StopAndRollbackPolicy,
+			activeDeploymentIDs: []string{"d-active"},
+			wantDeploymentID:    "d-new",
+			wantCreateCalls:     2,
+			wantStopCalls:       1,
+		},
+		{
+			name:                "stop and wait for rollback",
+			whenActive:          StopAndRollbackPolicy,
+			activeDeploymentIDs: []string{"d-active", "d-rollback"},
+			wantDeploymentID:    "d-new",
+			wantCreateCalls:     3,
+			wantStopCalls:       1,
+		},
+		{
+			name:             "finished in between",
+			whenActive:       WaitPolicy,
+			rejectedCreates:  1,
+			wantDeploymentID: "d-new",
+			wantCreateCalls:  2,
+		},
+		{
+			name:                "timeout",
+			whenActive:          WaitPolicy,
+			maxWaitDuration:     "1ns",
+			activeDeploymentIDs: []string{"d-active"},
+			wantCreateCalls:     1,
+			wantErr:             true,
+		},
+		{
+			name:                "attach",
+			whenActive:          AttachPolicy,
+			activeDeploymentIDs: []string{"d-active"},
+			activeAppSpecSha256: appSpecCodeDeployContext.AppSpecSha256(),
+			wantDeploymentID:    "d-active",
+			wantCreateCalls:     1,
+		},
+		{
+			name:                "attach to different AppSpec",
+			whenActive:          AttachPolicy,
+			activeDeploymentIDs: []string{"d-active"},
+			activeAppSpecSha256: "other",
+			wantCreateCalls:     1,
+			wantErr:             true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+			if err := flagContext.Parse([]string{
+				"-applicationName", "my-app",
+				"-deploymentGroupName", "my-group",
+				"-appSpecFileName", "appspec.yml",
+				"-whenActive", tt.whenActive,
+				"-pollInterval", "1ms",
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.maxWaitDuration != "" {
+				if err := flagContext.FlagSet.Set("maxWaitDuration", tt.maxWaitDuration); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := &mockCodeDeployClient{activeDeploymentIDs: tt.activeDeploymentIDs, rejectedCreates: tt.rejectedCreates, activeAppSpecSha256: tt.activeAppSpecSha256}
+			codeDeployContext, _ := deploy.NewCodeDeployContext(client, nil, nil).WithAppSpec(deploy.NewLambda("function-name", "function-alias", "42", "43"))
+			poller := deploy.NewDeploymentPoller(client, time.Millisecond, nil)
+
+			deploymentID, err := flagContext.createDeployment(context.Background(), codeDeployContext, poller)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("createDeployment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if deploymentID != tt.wantDeploymentID {
+				t.Errorf("createDeployment() got = %v, want %v", deploymentID, tt.wantDeploymentID)
+			}
+			if client.createCalls != tt.wantCreateCalls {
+				t.Errorf("unexpected CreateDeployment calls: %d", client.createCalls)
+			}
+			if len(client.stopInputs) != tt.wantStopCalls || (tt.wantStopCalls > 0 && !*client.stopInputs[0].AutoRollbackEnabled) {
+				t.Errorf("unexpected StopDeployment calls: %d", len(client.stopInputs))
+			}
+		})
+	}
+}
+
+func TestFlagContext_findUnchangedDeployment(t *testing.T) {
+	codeDeployContext, _ := (&deploy.CodeDeployContext{}).WithAppSpec(deploy.NewLambda("function-name", "function-alias", "42", "43"))
+	deployment := types.DeploymentInfo{
+		DeploymentId: aws.String("d-active"),
+		Status:       types.DeploymentStatusSucceeded,
+		CreateTime:   aws.Time(time.Now()),
+		Revision:     &types.RevisionLocation{AppSpecContent: &types.AppSpecContent{Sha256: aws.String(codeDeployContext.AppSpecSha256())}},
+	}
+
+	tests := []struct {
+		name             string
+		skipIfUnchanged  string
+		wantDeploymentID string
+	}{
+		{
+			name:            "disabled",
+			skipIfUnchanged: "0",
+		},
+		{
+			name:             "enabled",
+			skipIfUnchanged:  "24h",
+			wantDeploymentID: "d-active",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+			if err := flagContext.Parse([]string{
+				"-applicationName", "my-app",
+				"-deploymentGroupName", "my-group",
+				"-appSpecFileName", "appspec.yml",
+				"-skipIfUnchanged", tt.skipIfUnchanged,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			codeDeployContext.Client = &mockCodeDeployClient{activeDeploymentIDs: []string{"d-active"}, deployments: []types.DeploymentInfo{deployment}}
+
+			got, err := flagContext.findUnchangedDeployment(context.Background(), codeDeployContext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotDeploymentID string
+			if got != nil {
+				gotDeploymentID = *got.DeploymentId
+			}
+			if gotDeploymentID != tt.wantDeploymentID {
+				t.Errorf("findUnchangedDeployment() got = %v, want %v", gotDeploymentID, tt.wantDeploymentID)
+			}
+		})
+	}
+}
+
+func TestFlagContext_uploadServerBundle(t *testing.T) {
+	tempDirectory := t.TempDir()
+	t.Setenv("TMPDIR", tempDirectory)
+
+	bundleDirectory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bundleDirectory, deploy.ServerAppSpecFileName), []byte("version: 0.0\nos: linux\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+	if err := flagContext.Parse([]string{
+		"-applicationName", "my-app",
+		"-deploymentGroupName", "my-group",
+		"-target", "Server",
+		"-bundleDirectory", bundleDirectory,
+		"-s3Bucket", "bucket",
+		"-s3Key", "app.tgz",
+		"-s3BundleType", "tgz",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s3Client := &mockS3Client{}
+	codeDeployClient := &mockCodeDeployClient{}
+
+	revision, err := flagContext.uploadServerBundle(context.Background(), s3Client, codeDeployClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := deploy.S3Revision{Bucket: "bucket", Key: "app.tgz", BundleType: types.BundleTypeTarGZip, ETag: "etag"}
+	if revision != want {
+		t.Errorf("uploadServerBundle() got = %+v, want %+v", revision, want)
+	}
+
+	if s3Client.putInput == nil || *s3Client.putInput.Key != "app.tgz" || !bytes.HasPrefix(s3Client.body, []byte{0x1f, 0x8b}) {
+		t.Error("bundle not uploaded")
+	}
+
+	if entries, _ := os.ReadDir(tempDirectory); len(entries) > 0 {
+		t.Error("bundle file not removed")
+	}
+
+	if codeDeployClient.registerInput == nil || *codeDeployClient.registerInput.ApplicationName != "my-app" || *codeDeployClient.registerInput.Revision.S3Location.ETag != "etag" {
+		t.Error("revision not registered")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ciMetadata describes the CI run which creates the deployment.
+type ciMetadata struct {
+	Provider  string
+	CommitSHA string
+	Branch    string
+	RunURL    string
+	Actor     string
+}
+
+// descriptionData is available in description templates.
+type descriptionData struct {
+	Env map[string]string
+	CI  ciMetadata
+}
+
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, variable := range environ {
+		if name, value, found := strings.Cut(variable, "="); found {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// detectCI reads the metadata of GitHub Actions, GitLab CI, Jenkins or Buildkite from their environment variables.
+func detectCI(env map[string]string) ciMetadata {
+	switch {
+	case env["GITHUB_ACTIONS"] == "true":
+		return ciMetadata{
+			Provider:  "GitHub Actions",
+			CommitSHA: env["GITHUB_SHA"],
+			Branch:    env["GITHUB_REF_NAME"],
+			RunURL:    fmt.Sprintf("%s/%s/actions/runs/%s", env["GITHUB_SERVER_URL"], env["GITHUB_REPOSITORY"], env["GITHUB_RUN_ID"]),
+			Actor:     env["GITHUB_ACTOR"],
+		}
+	case env["GITLAB_CI"] == "true":
+		return ciMetadata{
+			Provider:  "GitLab CI",
+			CommitSHA: env["CI_COMMIT_SHA"],
+			Branch:    env["CI_COMMIT_REF_NAME"],
+			RunURL:    env["CI_PIPELINE_URL"],
+			Actor:     env["GITLAB_USER_LOGIN"],
+		}
+	case env["BUILDKITE"] == "true":
+		return ciMetadata{
+			Provider:  "Buildkite",
+			CommitSHA: env["BUILDKITE_COMMIT"],
+			Branch:    env["BUILDKITE_BRANCH"],
+			RunURL:    env["BUILDKITE_BUILD_URL"],
+			Actor:     env["BUILDKITE_BUILD_CREATOR"],
+		}
+	case len(env["JENKINS_URL"]) > 0:
+		branch := env["BRANCH_NAME"]
+		if branch == "" {
+			branch = env["GIT_BRANCH"]
+		}
+		return ciMetadata{
+			Provider:  "Jenkins",
+			CommitSHA: env["GIT_COMMIT"],
+			Branch:    branch,
+			RunURL:    env["BUILD_URL"],
+			// BUILD_USER_ID is only set by the "build user vars" plugin.
+			Actor: env["BUILD_USER_ID"],
+		}
+	}
+	return ciMetadata{}
+}
+
+func parseDescriptionTemplate(text string) (*template.Template, error) {
+	return template.New("description").Option("missingkey=zero").Parse(text)
+}
+
+// renderDescription executes the description template with the given environment variables and the CI metadata detected from them.
+func renderDescription(descriptionTemplate *template.Template, environ []string) (string, error) {
+	env := environMap(environ)
+
+	var b strings.Builder
+	if err := descriptionTemplate.Execute(&b, descriptionData{Env: env, CI: detectCI(env)}); err != nil {
+		return "", fmt.Errorf("cannot render description: %w", err)
+	}
+
+	return b.String(), nil
+}
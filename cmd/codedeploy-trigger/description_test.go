@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_detectCI(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		want    ciMetadata
+	}{
+		{
+			name: "GitHub Actions",
+			environ: []string{
+				"GITHUB_ACTIONS=true",
+				"GITHUB_SHA=abc123",
+				"GITHUB_REF_NAME=main",
+				"GITHUB_SERVER_URL=https://github.com",
+				"GITHUB_REPOSITORY=joeig/codedeploy-trigger",
+				"GITHUB_RUN_ID=42",
+				"GITHUB_ACTOR=octocat",
+			},
+			want: ciMetadata{Provider: "GitHub Actions", CommitSHA: "abc123", Branch: "main", RunURL: "https://github.com/joeig/codedeploy-trigger/actions/runs/42", Actor: "octocat"},
+		},
+		{
+			name:    "GitLab CI",
+			environ: []string{"GITLAB_CI=true", "CI_COMMIT_SHA=abc123", "CI_COMMIT_REF_NAME=main", "CI_PIPELINE_URL=https://gitlab.com/p/-/pipelines/42", "GITLAB_USER_LOGIN=user"},
+			want:    ciMetadata{Provider: "GitLab CI", CommitSHA: "abc123", Branch: "main", RunURL: "https://gitlab.com/p/-/pipelines/42", Actor: "user"},
+		},
+		{
+			name:    "Jenkins",
+			environ: []string{"JENKINS_URL=https://jenkins/", "GIT_COMMIT=abc123", "GIT_BRANCH=origin/main", "BUILD_URL=https://jenkins/job/app/42/"},
+			want:    ciMetadata{Provider: "Jenkins", CommitSHA: "abc123", Branch: "origin/main", RunURL: "https://jenkins/job/app/42/"},
+		},
+		{
+			name:    "Buildkite",
+			environ: []string{"BUILDKITE=true", "BUILDKITE_COMMIT=abc123", "BUILDKITE_BRANCH=main", "BUILDKITE_BUILD_URL=https://buildkite.com/org/app/builds/42", "BUILDKITE_BUILD_CREATOR=Jane"},
+			want:    ciMetadata{Provider: "Buildkite", CommitSHA: "abc123", Branch: "main", RunURL: "https://buildkite.com/org/app/builds/42", Actor: "Jane"},
+		},
+		{
+			name:    "unknown",
+			environ: []string{"CI=true"},
+			want:    ciMetadata{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectCI(environMap(tt.environ)); got != tt.want {
+				t.Errorf("detectCI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderDescription(t *testing.T) {
+	descriptionTemplate, err := parseDescriptionTemplate(`{{printf "%.7s" .CI.CommitSHA}} by {{.CI.Actor}}, version {{.Env.VERSION}}{{.Env.UNSET}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := renderDescription(descriptionTemplate, []string{"GITLAB_CI=true", "CI_COMMIT_SHA=0123456789abcdef", "GITLAB_USER_LOGIN=user", "VERSION=1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != "0123456 by user, version 1.2.3" {
+		t.Errorf("renderDescription() = %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"log"
+	"os"
+)
+
+// Exit codes, as documented in the README.
+const (
+	ExitSuccess            int = 0
+	ExitError              int = 1
+	ExitUsage              int = 2
+	ExitAWSConfig          int = 3
+	ExitCreateFailed       int = 4
+	ExitDeploymentFailed   int = 5
+	ExitDeploymentStopped  int = 6
+	ExitRolledBack         int = 7
+	ExitWaitTimeout        int = 8
+	ExitReady              int = 9
+	ExitVerificationFailed int = 10
+	ExitRollbackFailed     int = 11
+	ExitInterrupted        int = 130
+)
+
+var errAWSConfig = errors.New("cannot load AWS configuration")
+
+var errDeploymentReady = errors.New("deployment is ready to reroute traffic")
+
+var errVerificationFailed = errors.New("verification command failed")
+
+// exitCode maps an error onto the exit code table.
+func exitCode(err error) int {
+	var failedErr *deploy.DeploymentFailedError
+
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(err, deploy.ErrRollbackFailed):
+		return ExitRollbackFailed
+	case errors.As(err, &failedErr):
+		switch {
+		case failedErr.RollbackInfo != nil && failedErr.RollbackInfo.RollbackDeploymentId != nil:
+			return ExitRolledBack
+		case failedErr.Status == types.DeploymentStatusStopped:
+			return ExitDeploymentStopped
+		default:
+			return ExitDeploymentFailed
+		}
+	case errors.Is(err, deploy.ErrWaitTimeout):
+		return ExitWaitTimeout
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, errAWSConfig):
+		return ExitAWSConfig
+	case errors.Is(err, errDeploymentReady):
+		return ExitReady
+	case errors.Is(err, errVerificationFailed):
+		return ExitVerificationFailed
+	default:
+		return ExitError
+	}
+}
+
+// fatal logs the error and exits with its exit code.
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(exitCode(err))
+}
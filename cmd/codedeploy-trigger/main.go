@@ -2,24 +2,100 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 )
 
 const (
 	ECSTarget    string = "ECS"
 	LambdaTarget string = "Lambda"
+	ServerTarget string = "Server"
 )
 
+const (
+	DeployCommand   string = ""
+	ValidateCommand string = "validate"
+	WaitCommand     string = "wait"
+	ContinueCommand string = "continue"
+)
+
+const (
+	LeavePolicy           string = "leave"
+	StopPolicy            string = "stop"
+	StopAndRollbackPolicy string = "stop-and-rollback"
+	FailPolicy            string = "fail"
+	WaitPolicy            string = "wait"
+	AttachPolicy          string = "attach"
+	ContinuePolicy        string = "continue"
+	ExitPolicy            string = "exit"
+)
+
+const (
+	CompleteMilestone       string = "complete"
+	TrafficShiftedMilestone string = "traffic-shifted"
+)
+
+// stopWaitDuration limits how long to wait for CodeDeploy to confirm that a deployment stopped.
+const stopWaitDuration = 10 * time.Minute
+
+var targetServiceTypes = map[string]deploy.TargetServiceType{
+	ECSTarget:    deploy.ECSTargetServiceType,
+	LambdaTarget: deploy.LambdaTargetServiceType,
+}
+
+func checkPolicy(flagName, flagValue string) error {
+	if flagValue != LeavePolicy && flagValue != StopPolicy && flagValue != StopAndRollbackPolicy {
+		return fmt.Errorf("attribute %q must be either %q, %q or %q", flagName, LeavePolicy, StopPolicy, StopAndRollbackPolicy)
+	}
+	return nil
+}
+
+func checkWhenActivePolicy(flagName, flagValue string) error {
+	switch flagValue {
+	case FailPolicy, WaitPolicy, StopPolicy, StopAndRollbackPolicy, AttachPolicy:
+		return nil
+	}
+	return fmt.Errorf("attribute %q must be either %q, %q, %q, %q or %q", flagName, FailPolicy, WaitPolicy, StopPolicy, StopAndRollbackPolicy, AttachPolicy)
+}
+
+func checkWhenReadyPolicy(flagName, flagValue string) error {
+	switch flagValue {
+	case WaitPolicy, ContinuePolicy, ExitPolicy:
+		return nil
+	}
+	return fmt.Errorf("attribute %q must be either %q, %q or %q", flagName, WaitPolicy, ContinuePolicy, ExitPolicy)
+}
+
+func checkMilestone(flagName, flagValue string) error {
+	if flagValue != CompleteMilestone && flagValue != TrafficShiftedMilestone {
+		return fmt.Errorf("attribute %q must be either %q or %q", flagName, CompleteMilestone, TrafficShiftedMilestone)
+	}
+	return nil
+}
+
 func checkTarget(flagName, flagValue string) error {
-	if flagValue != ECSTarget && flagValue != LambdaTarget {
-		return fmt.Errorf("attribute %q must be either %q or %q", flagName, ECSTarget, LambdaTarget)
+	if flagValue != ECSTarget && flagValue != LambdaTarget && flagValue != ServerTarget {
+		return fmt.Errorf("attribute %q must be either %q, %q or %q", flagName, ECSTarget, LambdaTarget, ServerTarget)
 	}
 	return nil
 }
@@ -45,36 +121,252 @@ func checkDuration(flagName string, flagValue time.Duration) error {
 	return nil
 }
 
+func checkHooks(flagName string, target string, hooks []deploy.Hook) error {
+	for _, hook := range hooks {
+		if !slices.Contains(deploy.HookNames(targetServiceTypes[target]), hook.Name) {
+			return fmt.Errorf("attribute %q contains hook %q which is not supported for target %q", flagName, hook.Name, target)
+		}
+	}
+	return nil
+}
+
+func checkAssignPublicIP(flagName string, flagValue string) error {
+	switch deploy.AssignPublicIP(flagValue) {
+	case "", deploy.AssignPublicIPEnabled, deploy.AssignPublicIPDisabled:
+		return nil
+	}
+	return fmt.Errorf("attribute %q must be either %q or %q", flagName, deploy.AssignPublicIPEnabled, deploy.AssignPublicIPDisabled)
+}
+
+func checkAutoRollbackEvents(flagName string, flagValue string) error {
+	for _, event := range splitList(flagValue) {
+		if !slices.Contains(types.AutoRollbackEvent("").Values(), types.AutoRollbackEvent(event)) {
+			return fmt.Errorf("attribute %q contains unknown event %q", flagName, event)
+		}
+	}
+	return nil
+}
+
+func checkFileExistsBehavior(flagName string, flagValue string) error {
+	switch types.FileExistsBehavior(flagValue) {
+	case "", types.FileExistsBehaviorDisallow, types.FileExistsBehaviorOverwrite, types.FileExistsBehaviorRetain:
+		return nil
+	}
+	return fmt.Errorf("attribute %q must be either %q, %q or %q", flagName, types.FileExistsBehaviorDisallow, types.FileExistsBehaviorOverwrite, types.FileExistsBehaviorRetain)
+}
+
+func checkBundleType(flagName string, flagValue string) error {
+	if !slices.Contains(types.BundleType("").Values(), types.BundleType(flagValue)) {
+		return fmt.Errorf("attribute %q must be either %q, %q, %q, %q or %q", flagName, types.BundleTypeZip, types.BundleTypeTarGZip, types.BundleTypeTar, types.BundleTypeYaml, types.BundleTypeJson)
+	}
+	return nil
+}
+
+func checkRequires(flagName string, flagSet bool, requiredFlagName string, requiredFlagValue string) error {
+	if flagSet && len(requiredFlagValue) == 0 {
+		return fmt.Errorf("attribute %q requires attribute %q", flagName, requiredFlagName)
+	}
+	return nil
+}
+
+func checkExclusive(flagName string, otherFlagName string, otherFlagValue string) error {
+	if len(otherFlagValue) > 0 {
+		return fmt.Errorf("attribute %q must not be used together with attribute %q", flagName, otherFlagName)
+	}
+	return nil
+}
+
+// checkExclusiveFlags rejects each of the other flags which has been set, since it would be ignored.
+func checkExclusiveFlags(flagName string, flagSet *flag.FlagSet, otherFlagNames ...string) error {
+	var err error
+	flagSet.Visit(func(setFlag *flag.Flag) {
+		if err == nil && slices.Contains(otherFlagNames, setFlag.Name) {
+			err = fmt.Errorf("attribute %q must not be used together with attribute %q", flagName, setFlag.Name)
+		}
+	})
+	return err
+}
+
+// appSpecFlagNames are the flags which generate the AppSpec of the ECS and Lambda targets.
+var appSpecFlagNames = []string{
+	"taskDefinitionARN", "taskDefinitionTemplate", "image", "containerName", "containerPort", "platformVersion", "subnets", "securityGroups", "assignPublicIp", "capacityProviderStrategy",
+	"functionName", "functionAlias", "currentVersion", "targetVersion", "publishVersion", "codeSha256", "hook",
+}
+
+func splitList(flagValue string) []string {
+	if len(flagValue) == 0 {
+		return nil
+	}
+	return strings.Split(flagValue, ",")
+}
+
+// capacityProviderStrategyFlags collects comma-separated "CapacityProvider:Weight[:Base]" items.
+type capacityProviderStrategyFlags []deploy.CapacityProviderStrategyItem
+
+func (c *capacityProviderStrategyFlags) String() string {
+	items := make([]string, 0, len(*c))
+	for _, item := range *c {
+		items = append(items, fmt.Sprintf("%s:%d:%d", item.CapacityProvider, item.Weight, item.Base))
+	}
+	return strings.Join(items, ",")
+}
+
+func (c *capacityProviderStrategyFlags) Set(value string) error {
+	for _, rawItem := range strings.Split(value, ",") {
+		fields := strings.Split(rawItem, ":")
+		if len(fields) < 2 || len(fields) > 3 || len(fields[0]) == 0 {
+			return fmt.Errorf("capacity provider strategy %q must have the format CapacityProvider:Weight[:Base]", rawItem)
+		}
+
+		item := deploy.CapacityProviderStrategyItem{CapacityProvider: fields[0]}
+
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight < 0 || weight > deploy.CapacityProviderMaxWeight {
+			return fmt.Errorf("capacity provider strategy %q contains an invalid weight", rawItem)
+		}
+		item.Weight = weight
+
+		if len(fields) == 3 {
+			base, err := strconv.Atoi(fields[2])
+			if err != nil || base < 0 || base > deploy.CapacityProviderMaxBase {
+				return fmt.Errorf("capacity provider strategy %q contains an invalid base", rawItem)
+			}
+			item.Base = base
+		}
+
+		*c = append(*c, item)
+	}
+	return nil
+}
+
+// hookFlags collects repeated "-hook LifecycleEvent=FunctionName" flags.
+type hookFlags []deploy.Hook
+
+func (h *hookFlags) String() string {
+	hooks := make([]string, 0, len(*h))
+	for _, hook := range *h {
+		hooks = append(hooks, fmt.Sprintf("%s=%s", hook.Name, hook.FunctionName))
+	}
+	return strings.Join(hooks, ",")
+}
+
+func (h *hookFlags) Set(value string) error {
+	name, functionName, found := strings.Cut(value, "=")
+	if !found || len(name) == 0 || len(functionName) == 0 {
+		return fmt.Errorf("hook %q must have the format LifecycleEvent=FunctionName", value)
+	}
+	*h = append(*h, deploy.Hook{Name: deploy.HookName(name), FunctionName: functionName})
+	return nil
+}
+
 type FlagContext struct {
 	FlagSet *flag.FlagSet
+	Command string
 
-	maxWaitDuration     *time.Duration
-	applicationName     *string
-	deploymentGroupName *string
-	appSpecFileName     *string
-	target              *string
-	taskDefinitionARN   *string
-	containerName       *string
-	containerPort       *int
-	functionName        *string
-	functionAlias       *string
-	currentVersion      *string
-	targetVersion       *string
+	maxWaitDuration        *time.Duration
+	pollInterval           *time.Duration
+	onTimeout              *string
+	onInterrupt            *string
+	deploymentID           *string
+	whenActive             *string
+	whenReady              *string
+	verifyCommand          *string
+	returnWhen             *string
+	terminateOriginal      *bool
+	skipIfUnchanged        *time.Duration
+	deploymentConfigName   *string
+	autoRollbackEvents     *string
+	disableAutoRollback    *bool
+	alarms                 *string
+	disableAlarms          *bool
+	description            *string
+	descriptionTemplate    *template.Template
+	fileExistsBehavior     *string
+	ignoreStopFailures     *bool
+	updateOutdatedOnly     *bool
+	dryRun                 *bool
+	applicationName        *string
+	deploymentGroupName    *string
+	appSpecFileName        *string
+	s3Bucket               *string
+	s3Key                  *string
+	s3BundleType           *string
+	s3Version              *string
+	s3ETag                 *string
+	gitHubRepository       *string
+	gitHubCommitID         *string
+	bundleDirectory        *string
+	target                 *string
+	taskDefinitionARN      *string
+	taskDefinitionTemplate *string
+	image                  *string
+	containerName          *string
+	containerPort          *int
+	platformVersion        *string
+	subnets                *string
+	securityGroups         *string
+	assignPublicIP         *string
+	capacityProviders      capacityProviderStrategyFlags
+	functionName           *string
+	functionAlias          *string
+	currentVersion         *string
+	targetVersion          *string
+	publishVersion         *bool
+	codeSha256             *string
+	hooks                  hookFlags
 }
 
 func (f *FlagContext) Parse(arguments []string) error {
 	f.maxWaitDuration = f.FlagSet.Duration("maxWaitDuration", 30*time.Minute, "Max wait duration for a deployment to finish")
+	f.pollInterval = f.FlagSet.Duration("pollInterval", deploy.DefaultPollInterval, "Interval for polling the deployment status while waiting")
+	f.onTimeout = f.FlagSet.String("onTimeout", LeavePolicy, "What to do with the deployment if maxWaitDuration elapsed (\"leave\", \"stop\" or \"stop-and-rollback\")")
+	f.onInterrupt = f.FlagSet.String("onInterrupt", LeavePolicy, "What to do with the deployment on SIGINT or SIGTERM (\"leave\", \"stop\" or \"stop-and-rollback\")")
+	f.whenActive = f.FlagSet.String("whenActive", FailPolicy, "What to do if the deployment group already has an active deployment (\"fail\", \"wait\", \"stop\", \"stop-and-rollback\" or \"attach\" if its AppSpec is equal)")
+	f.whenReady = f.FlagSet.String("whenReady", WaitPolicy, "What to do if the deployment is ready to reroute traffic (\"wait\" for the deployment group's wait time, \"continue\" or \"exit\")")
+	f.verifyCommand = f.FlagSet.String("verifyCommand", "", "Shell command which must succeed before traffic is rerouted (optional; requires whenReady \"continue\")")
+	f.returnWhen = f.FlagSet.String("returnWhen", CompleteMilestone, "When to report success (\"complete\", or \"traffic-shifted\" to skip the blue/green termination wait)")
+	f.terminateOriginal = f.FlagSet.Bool("terminateOriginal", false, "End the blue/green termination wait by terminating the original task set or instances as soon as traffic is shifted")
+	f.skipIfUnchanged = f.FlagSet.Duration("skipIfUnchanged", 0, "Don't create a deployment if the latest deployment of the group created within this duration deployed the same AppSpec, and follow it if it is still active (optional)")
+	f.deploymentID = f.FlagSet.String("deploymentId", "", "Deployment ID to wait for or continue (wait and continue commands only; optional, the active deployment of applicationName and deploymentGroupName if unset)")
+	f.deploymentConfigName = f.FlagSet.String("deploymentConfigName", "", "Deployment configuration overriding the deployment group's, e.g. \"CodeDeployDefault.ECSAllAtOnce\" (optional)")
+	f.autoRollbackEvents = f.FlagSet.String("autoRollbackEvents", "", "Comma-separated events which roll back the deployment automatically, overriding the deployment group's (\"DEPLOYMENT_FAILURE\", \"DEPLOYMENT_STOP_ON_ALARM\" or \"DEPLOYMENT_STOP_ON_REQUEST\"; optional)")
+	f.disableAutoRollback = f.FlagSet.Bool("disableAutoRollback", false, "Disable automatic rollbacks of the deployment group for this deployment")
+	f.alarms = f.FlagSet.String("alarms", "", "Comma-separated CloudWatch alarm names to monitor, replacing all alarms of the deployment group (optional)")
+	f.disableAlarms = f.FlagSet.Bool("disableAlarms", false, "Disable monitoring the CloudWatch alarms of the deployment group for this deployment")
+	f.description = f.FlagSet.String("description", "", "Deployment description as Go template, e.g. \"{{.CI.Provider}} {{.CI.RunURL}} by {{.CI.Actor}}\" or \"{{.Env.VERSION}}\" (optional)")
+	f.fileExistsBehavior = f.FlagSet.String("fileExistsBehavior", "", "How EC2/on-premises deployments handle existing files (\"DISALLOW\", \"OVERWRITE\" or \"RETAIN\"; optional)")
+	f.ignoreStopFailures = f.FlagSet.Bool("ignoreApplicationStopFailures", false, "Continue EC2/on-premises deployments if the ApplicationStop lifecycle event fails")
+	f.updateOutdatedOnly = f.FlagSet.Bool("updateOutdatedInstancesOnly", false, "Only deploy to EC2/on-premises instances which don't run the application's latest revision")
+	f.dryRun = f.FlagSet.Bool("dryRun", false, "Print the CreateDeployment request instead of sending it (no AWS credentials required)")
 	f.applicationName = f.FlagSet.String("applicationName", "", "CodeDeploy application name")
 	f.deploymentGroupName = f.FlagSet.String("deploymentGroupName", "", "CodeDeploy deployment group name")
-	f.target = f.FlagSet.String("target", "", "Deployment target (\"ECS\" or \"Lambda\"; if appSpecFileName is unset)")
-	f.appSpecFileName = f.FlagSet.String("appSpecFileName", "", "Custom AppSpec file name")
-	f.taskDefinitionARN = f.FlagSet.String("taskDefinitionARN", "", "ECS task definition ARN (if appSpecFileName is unset)")
-	f.containerName = f.FlagSet.String("containerName", "", "ECS container name (if appSpecFileName is unset)")
-	f.containerPort = f.FlagSet.Int("containerPort", 0, "ECS container port (if appSpecFileName is unset)")
+	f.target = f.FlagSet.String("target", "", "Deployment target (\"ECS\", \"Lambda\" or \"Server\" for EC2/on-premises; if appSpecFileName is unset)")
+	f.appSpecFileName = f.FlagSet.String("appSpecFileName", "", "Custom AppSpec file name (JSON or YAML)")
+	f.s3Bucket = f.FlagSet.String("s3Bucket", "", "S3 bucket of the revision bundle (instead of appSpecFileName or target, or to upload the bundle of target \"Server\" to)")
+	f.s3Key = f.FlagSet.String("s3Key", "", "S3 object key of the revision bundle (if s3Bucket is set)")
+	f.s3BundleType = f.FlagSet.String("s3BundleType", string(types.BundleTypeZip), "Type of the revision bundle (\"zip\", \"tgz\", \"tar\", \"YAML\" or \"JSON\", only \"zip\" or \"tgz\" for target \"Server\"; if s3Bucket is set)")
+	f.s3Version = f.FlagSet.String("s3Version", "", "S3 object version of the revision bundle (optional; if s3Bucket is set)")
+	f.s3ETag = f.FlagSet.String("s3ETag", "", "S3 ETag of the revision bundle (optional; if s3Bucket is set)")
+	f.gitHubRepository = f.FlagSet.String("gitHubRepository", "", "GitHub repository of the revision as account/repository (instead of appSpecFileName or target)")
+	f.gitHubCommitID = f.FlagSet.String("gitHubCommitId", "", "GitHub commit ID of the revision (if gitHubRepository is set)")
+	f.bundleDirectory = f.FlagSet.String("bundleDirectory", "", "Directory containing appspec.yml to bundle, upload to s3Bucket and register as revision (if target is \"Server\")")
+	f.taskDefinitionARN = f.FlagSet.String("taskDefinitionARN", "", "ECS task definition ARN, family or family:revision (if appSpecFileName is unset)")
+	f.taskDefinitionTemplate = f.FlagSet.String("taskDefinitionTemplate", "", "ECS task definition JSON file to register as new revision instead of taskDefinitionARN (optional; if appSpecFileName is unset)")
+	f.image = f.FlagSet.String("image", "", "ECS container image to set for containerName before registering a new revision of taskDefinitionTemplate or taskDefinitionARN (optional; if appSpecFileName is unset)")
+	f.containerName = f.FlagSet.String("containerName", "", "ECS container name (optional, detected from the task definition if unset; if appSpecFileName is unset)")
+	f.containerPort = f.FlagSet.Int("containerPort", 0, "ECS container port (optional, detected from the task definition if unset; if appSpecFileName is unset)")
+	f.platformVersion = f.FlagSet.String("platformVersion", "", "ECS Fargate platform version (optional; if appSpecFileName is unset)")
+	f.subnets = f.FlagSet.String("subnets", "", "Comma-separated ECS awsvpc subnet IDs (optional; if appSpecFileName is unset)")
+	f.securityGroups = f.FlagSet.String("securityGroups", "", "Comma-separated ECS awsvpc security group IDs (optional; requires subnets; if appSpecFileName is unset)")
+	f.assignPublicIP = f.FlagSet.String("assignPublicIp", "", "ECS awsvpc public IP assignment (\"ENABLED\" or \"DISABLED\"; optional; requires subnets; if appSpecFileName is unset)")
+	f.FlagSet.Var(&f.capacityProviders, "capacityProviderStrategy", "Comma-separated ECS capacity provider strategy as CapacityProvider:Weight[:Base] (optional; if appSpecFileName is unset)")
 	f.functionName = f.FlagSet.String("functionName", "", "Lambda function name (if appSpecFileName is unset)")
 	f.functionAlias = f.FlagSet.String("functionAlias", "", "Lambda function alias (if appSpecFileName is unset)")
-	f.currentVersion = f.FlagSet.String("currentVersion", "", "Current Lambda function version (if appSpecFileName is unset)")
-	f.targetVersion = f.FlagSet.String("targetVersion", "", "Target Lambda function version (if appSpecFileName is unset)")
+	f.currentVersion = f.FlagSet.String("currentVersion", "", "Current Lambda function version (optional, resolved from functionAlias if unset; if appSpecFileName is unset)")
+	f.targetVersion = f.FlagSet.String("targetVersion", "", "Target Lambda function version (if appSpecFileName and publishVersion are unset)")
+	f.publishVersion = f.FlagSet.Bool("publishVersion", false, "Publish a new Lambda function version and use it as target version (if appSpecFileName is unset)")
+	f.codeSha256 = f.FlagSet.String("codeSha256", "", "Only publish the Lambda function version if its code has this base64-encoded SHA256 hash (optional; requires publishVersion)")
+	f.FlagSet.Var(&f.hooks, "hook", "Lifecycle event hook as LifecycleEvent=FunctionName, can be repeated (if appSpecFileName is unset)")
 
 	if err := f.FlagSet.Parse(arguments); err != nil {
 		return err
@@ -84,14 +376,87 @@ func (f *FlagContext) Parse(arguments []string) error {
 }
 
 func (f *FlagContext) validate() error {
-	if err := checkDuration("maxWaitDuration", *f.maxWaitDuration); err != nil {
-		return err
+	if f.Command == DeployCommand || f.Command == WaitCommand || f.Command == ContinueCommand {
+		if err := checkDuration("maxWaitDuration", *f.maxWaitDuration); err != nil {
+			return err
+		}
+		if err := checkDuration("pollInterval", *f.pollInterval); err != nil {
+			return err
+		}
+		if err := checkPolicy("onTimeout", *f.onTimeout); err != nil {
+			return err
+		}
+		if err := checkPolicy("onInterrupt", *f.onInterrupt); err != nil {
+			return err
+		}
+		if err := checkWhenReadyPolicy("whenReady", *f.whenReady); err != nil {
+			return err
+		}
+		if len(*f.verifyCommand) > 0 && *f.whenReady != ContinuePolicy {
+			return fmt.Errorf("attribute \"verifyCommand\" requires attribute \"whenReady\" to be %q", ContinuePolicy)
+		}
+		if err := checkMilestone("returnWhen", *f.returnWhen); err != nil {
+			return err
+		}
 	}
-	if err := checkNotEmpty("applicationName", *f.applicationName); err != nil {
-		return err
+
+	if f.Command == WaitCommand || f.Command == ContinueCommand {
+		if len(*f.deploymentID) == 0 && (len(*f.applicationName) == 0 || len(*f.deploymentGroupName) == 0) {
+			return errors.New("attribute \"deploymentId\" or attributes \"applicationName\" and \"deploymentGroupName\" must be set")
+		}
+		return nil
 	}
-	if err := checkNotEmpty("deploymentGroupName", *f.deploymentGroupName); err != nil {
-		return err
+
+	if f.Command == DeployCommand {
+		if err := checkWhenActivePolicy("whenActive", *f.whenActive); err != nil {
+			return err
+		}
+		if *f.skipIfUnchanged < 0 {
+			return errors.New("attribute \"skipIfUnchanged\" must not be negative")
+		}
+		if err := checkAutoRollbackEvents("autoRollbackEvents", *f.autoRollbackEvents); err != nil {
+			return err
+		}
+		if *f.disableAutoRollback {
+			if err := checkExclusive("disableAutoRollback", "autoRollbackEvents", *f.autoRollbackEvents); err != nil {
+				return err
+			}
+		}
+		if *f.disableAlarms {
+			if err := checkExclusive("disableAlarms", "alarms", *f.alarms); err != nil {
+				return err
+			}
+		}
+		if err := checkFileExistsBehavior("fileExistsBehavior", *f.fileExistsBehavior); err != nil {
+			return err
+		}
+		if len(*f.description) > 0 {
+			descriptionTemplate, err := parseDescriptionTemplate(*f.description)
+			if err != nil {
+				return fmt.Errorf("attribute \"description\" contains an invalid template: %w", err)
+			}
+			f.descriptionTemplate = descriptionTemplate
+		}
+		if err := checkNotEmpty("applicationName", *f.applicationName); err != nil {
+			return err
+		}
+		if err := checkNotEmpty("deploymentGroupName", *f.deploymentGroupName); err != nil {
+			return err
+		}
+	}
+
+	if *f.target == ServerTarget {
+		return f.validateServerTarget()
+	}
+
+	if len(*f.s3Bucket) > 0 || len(*f.gitHubRepository) > 0 {
+		return f.validateRevisionSource()
+	}
+
+	if len(*f.appSpecFileName) > 0 {
+		if err := checkExclusiveFlags("appSpecFileName", f.FlagSet, "hook", "containerPort", "platformVersion", "subnets", "securityGroups", "assignPublicIp", "capacityProviderStrategy", "taskDefinitionTemplate", "image", "publishVersion", "codeSha256"); err != nil {
+			return err
+		}
 	}
 
 	if *f.appSpecFileName == "" {
@@ -100,13 +465,31 @@ func (f *FlagContext) validate() error {
 		}
 
 		if *f.target == ECSTarget {
-			if err := checkNotEmpty("taskDefinitionARN", *f.taskDefinitionARN); err != nil {
+			if len(*f.taskDefinitionTemplate) > 0 {
+				if err := checkExclusive("taskDefinitionTemplate", "taskDefinitionARN", *f.taskDefinitionARN); err != nil {
+					return err
+				}
+			} else {
+				if err := checkNotEmpty("taskDefinitionARN", *f.taskDefinitionARN); err != nil {
+					return err
+				}
+			}
+			if (len(*f.taskDefinitionTemplate) > 0 || len(*f.image) > 0) && (f.Command != DeployCommand || *f.dryRun) {
+				return errors.New("registering a task definition is only supported when creating a deployment")
+			}
+			if (len(*f.taskDefinitionTemplate) > 0 || len(*f.image) > 0) && (*f.skipIfUnchanged > 0 || *f.whenActive == AttachPolicy) {
+				return fmt.Errorf("registering a task definition must not be used together with attribute \"skipIfUnchanged\" or \"whenActive\" %q, because every new revision changes the AppSpec", AttachPolicy)
+			}
+			if err := checkPortRange("containerPort", *f.containerPort); err != nil {
 				return err
 			}
-			if err := checkNotEmpty("containerName", *f.containerName); err != nil {
+			if err := checkAssignPublicIP("assignPublicIp", *f.assignPublicIP); err != nil {
 				return err
 			}
-			if err := checkPortRange("containerPort", *f.containerPort); err != nil {
+			if err := checkRequires("securityGroups", len(*f.securityGroups) > 0, "subnets", *f.subnets); err != nil {
+				return err
+			}
+			if err := checkRequires("assignPublicIp", len(*f.assignPublicIP) > 0, "subnets", *f.subnets); err != nil {
 				return err
 			}
 		}
@@ -118,71 +501,934 @@ func (f *FlagContext) validate() error {
 			if err := checkNotEmpty("functionAlias", *f.functionAlias); err != nil {
 				return err
 			}
-			if err := checkNotEmpty("currentVersion", *f.currentVersion); err != nil {
-				return err
-			}
-			if err := checkNotEmpty("targetVersion", *f.targetVersion); err != nil {
-				return err
+			if *f.publishVersion {
+				if err := checkExclusive("publishVersion", "targetVersion", *f.targetVersion); err != nil {
+					return err
+				}
+				if f.Command != DeployCommand || *f.dryRun {
+					return errors.New("attribute \"publishVersion\" is only supported when creating a deployment")
+				}
+			} else {
+				if err := checkNotEmpty("targetVersion", *f.targetVersion); err != nil {
+					return err
+				}
+				if len(*f.codeSha256) > 0 {
+					return errors.New("attribute \"codeSha256\" requires attribute \"publishVersion\"")
+				}
 			}
 		}
+
+		if err := checkHooks("hook", *f.target, f.hooks); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func main() {
-	flagContext := &FlagContext{FlagSet: flag.CommandLine}
-	if err := flagContext.Parse(os.Args[1:]); err != nil {
-		log.Fatalln(err)
+// validateRevisionSource validates S3 and GitHub revisions, which replace the AppSpec.
+func (f *FlagContext) validateRevisionSource() error {
+	if f.Command != DeployCommand {
+		return errors.New("S3 and GitHub revisions are only supported when creating a deployment or with attribute \"dryRun\"")
 	}
 
-	awsConfig, err := config.LoadDefaultConfig(context.Background())
-	if err != nil {
-		log.Fatalf("cannot load AWS configuration: %s", err)
+	revisionFlagName := "s3Bucket"
+	if len(*f.gitHubRepository) > 0 {
+		revisionFlagName = "gitHubRepository"
 	}
 
-	codeDeployClient := codedeploy.NewFromConfig(awsConfig)
-	codeDeployContext := &deploy.CodeDeployContext{
-		Client:                     codeDeployClient,
-		DeploymentSuccessfulWaiter: codedeploy.NewDeploymentSuccessfulWaiter(codeDeployClient).Wait,
-		FileReader:                 os.ReadFile,
+	if len(*f.gitHubRepository) > 0 {
+		if err := checkExclusive("gitHubRepository", "s3Bucket", *f.s3Bucket); err != nil {
+			return err
+		}
+	}
+	if err := checkExclusive(revisionFlagName, "appSpecFileName", *f.appSpecFileName); err != nil {
+		return err
+	}
+	if err := checkExclusive(revisionFlagName, "target", *f.target); err != nil {
+		return err
+	}
+	if err := checkExclusive(revisionFlagName, "bundleDirectory", *f.bundleDirectory); err != nil {
+		return err
+	}
+	if err := checkExclusiveFlags(revisionFlagName, f.FlagSet, appSpecFlagNames...); err != nil {
+		return err
+	}
+	if *f.skipIfUnchanged > 0 || *f.whenActive == AttachPolicy {
+		return fmt.Errorf("attribute %q must not be used together with attribute \"skipIfUnchanged\" or \"whenActive\" %q, which compare AppSpecs", revisionFlagName, AttachPolicy)
 	}
 
-	log.Printf("creating deployment for application %q (group %q)", *flagContext.applicationName, *flagContext.deploymentGroupName)
+	if len(*f.s3Bucket) > 0 {
+		if err := checkNotEmpty("s3Key", *f.s3Key); err != nil {
+			return err
+		}
+		return checkBundleType("s3BundleType", *f.s3BundleType)
+	}
+
+	return checkNotEmpty("gitHubCommitId", *f.gitHubCommitID)
+}
+
+// validateServerTarget validates EC2/on-premises deployments, whose bundle is uploaded to S3 before creating the deployment.
+func (f *FlagContext) validateServerTarget() error {
+	if f.Command != DeployCommand || *f.dryRun {
+		return fmt.Errorf("target %q is only supported when creating a deployment", ServerTarget)
+	}
+
+	if err := checkExclusive("target", "appSpecFileName", *f.appSpecFileName); err != nil {
+		return err
+	}
+	if err := checkExclusive("target", "gitHubRepository", *f.gitHubRepository); err != nil {
+		return err
+	}
+	if err := checkExclusive("target", "s3Version", *f.s3Version); err != nil {
+		return err
+	}
+	if err := checkExclusive("target", "s3ETag", *f.s3ETag); err != nil {
+		return err
+	}
+	if *f.skipIfUnchanged > 0 || *f.whenActive == AttachPolicy {
+		return fmt.Errorf("target %q must not be used together with attribute \"skipIfUnchanged\" or \"whenActive\" %q, which compare AppSpecs", ServerTarget, AttachPolicy)
+	}
+
+	if err := checkNotEmpty("bundleDirectory", *f.bundleDirectory); err != nil {
+		return err
+	}
+	if err := checkNotEmpty("s3Bucket", *f.s3Bucket); err != nil {
+		return err
+	}
+	if err := checkNotEmpty("s3Key", *f.s3Key); err != nil {
+		return err
+	}
+	if bundleType := types.BundleType(*f.s3BundleType); bundleType != types.BundleTypeZip && bundleType != types.BundleTypeTarGZip {
+		return fmt.Errorf("attribute \"s3BundleType\" must be either %q or %q for target %q", types.BundleTypeZip, types.BundleTypeTarGZip, ServerTarget)
+	}
+
+	if err := checkHooks("hook", *f.target, f.hooks); err != nil {
+		return err
+	}
+
+	return checkExclusiveFlags("target", f.FlagSet, appSpecFlagNames...)
+}
+
+// revisionSource returns the S3 or GitHub revision, or nil if the revision is an AppSpec or is bundled for target "Server".
+func (f *FlagContext) revisionSource() deploy.RevisionSource {
+	if len(*f.s3Bucket) > 0 && *f.target != ServerTarget {
+		return deploy.S3Revision{
+			Bucket:     *f.s3Bucket,
+			Key:        *f.s3Key,
+			BundleType: types.BundleType(*f.s3BundleType),
+			Version:    *f.s3Version,
+			ETag:       *f.s3ETag,
+		}
+	}
+
+	if len(*f.gitHubRepository) > 0 {
+		return deploy.GitHubRevision{Repository: *f.gitHubRepository, CommitID: *f.gitHubCommitID}
+	}
+
+	return nil
+}
+
+func (f *FlagContext) ecsOptions() []deploy.ECSOption {
+	var options []deploy.ECSOption
+
+	if len(*f.platformVersion) > 0 {
+		options = append(options, deploy.WithPlatformVersion(*f.platformVersion))
+	}
+
+	if len(*f.subnets) > 0 {
+		options = append(options, deploy.WithAwsvpcConfiguration(splitList(*f.subnets), splitList(*f.securityGroups), deploy.AssignPublicIP(*f.assignPublicIP)))
+	}
+
+	if len(f.capacityProviders) > 0 {
+		options = append(options, deploy.WithCapacityProviderStrategy(f.capacityProviders...))
+	}
 
-	if *flagContext.appSpecFileName == "" {
-		var appSpec *deploy.AppSpec
+	return options
+}
+
+func (f *FlagContext) deploymentOptions() ([]deploy.DeploymentOption, error) {
+	var options []deploy.DeploymentOption
+
+	if len(*f.deploymentConfigName) > 0 {
+		options = append(options, deploy.WithDeploymentConfigName(*f.deploymentConfigName))
+	}
 
-		if *flagContext.target == ECSTarget {
-			appSpec = deploy.NewECS(*flagContext.taskDefinitionARN, *flagContext.containerName, *flagContext.containerPort)
+	if len(*f.autoRollbackEvents) > 0 {
+		var events []types.AutoRollbackEvent
+		for _, event := range splitList(*f.autoRollbackEvents) {
+			events = append(events, types.AutoRollbackEvent(event))
 		}
+		options = append(options, deploy.WithAutoRollback(events...))
+	}
+
+	if *f.disableAutoRollback {
+		options = append(options, deploy.WithoutAutoRollback())
+	}
+
+	if len(*f.alarms) > 0 {
+		options = append(options, deploy.WithAlarms(splitList(*f.alarms)...))
+	}
+
+	if *f.disableAlarms {
+		options = append(options, deploy.WithoutAlarms())
+	}
 
-		if *flagContext.target == LambdaTarget {
-			appSpec = deploy.NewLambda(*flagContext.functionName, *flagContext.functionAlias, *flagContext.currentVersion, *flagContext.targetVersion)
+	if f.descriptionTemplate != nil {
+		description, err := renderDescription(f.descriptionTemplate, os.Environ())
+		if err != nil {
+			return nil, err
 		}
+		options = append(options, deploy.WithDescription(description))
+	}
+
+	if len(*f.fileExistsBehavior) > 0 {
+		options = append(options, deploy.WithFileExistsBehavior(types.FileExistsBehavior(*f.fileExistsBehavior)))
+	}
+
+	if *f.ignoreStopFailures {
+		options = append(options, deploy.WithIgnoreApplicationStopFailures())
+	}
 
-		if _, err := codeDeployContext.WithAppSpec(appSpec); err != nil {
-			log.Fatal(err.Error())
+	if *f.updateOutdatedOnly {
+		options = append(options, deploy.WithUpdateOutdatedInstancesOnly())
+	}
+
+	return options, nil
+}
+
+// awsClients creates AWS clients on first use, so that commands which don't talk to AWS work without credentials.
+type awsClients struct {
+	awsConfig *aws.Config
+}
+
+func (a *awsClients) config(ctx context.Context) (aws.Config, error) {
+	if a.awsConfig == nil {
+		awsConfig, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("%w: %w", errAWSConfig, err)
 		}
-	} else {
-		if _, err := codeDeployContext.WithAppSpecFile(*flagContext.appSpecFileName); err != nil {
-			log.Fatal(err.Error())
+		// Credentials are resolved lazily, so they are retrieved here to report missing ones as configuration error.
+		if _, err := awsConfig.Credentials.Retrieve(ctx); err != nil {
+			return aws.Config{}, fmt.Errorf("%w: %w", errAWSConfig, err)
 		}
+		a.awsConfig = &awsConfig
 	}
+	return *a.awsConfig, nil
+}
 
-	deploymentID, err := codeDeployContext.CreateDeployment(context.Background(), *flagContext.applicationName, *flagContext.deploymentGroupName)
+func (a *awsClients) lambda(ctx context.Context) (deploy.LambdaClient, error) {
+	awsConfig, err := a.config(ctx)
 	if err != nil {
-		log.Printf("cannot create deployment: %s", err)
-		os.Exit(1)
+		return nil, err
 	}
+	return lambda.NewFromConfig(awsConfig), nil
+}
 
-	log.Printf("deployment ID %q created", deploymentID)
-	log.Printf("waiting for deployment ID %q to finish", deploymentID)
+func (a *awsClients) ecs(ctx context.Context) (deploy.ECSClient, error) {
+	awsConfig, err := a.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ecs.NewFromConfig(awsConfig), nil
+}
 
-	if err := codeDeployContext.WaitForSuccessfulDeployment(context.Background(), deploymentID, *flagContext.maxWaitDuration); err != nil {
-		log.Printf("deployment failed: %s", err)
-		os.Exit(1)
+func (a *awsClients) s3(ctx context.Context) (deploy.S3Client, error) {
+	awsConfig, err := a.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(awsConfig), nil
+}
+
+// registerECSAppSpec registers a new task definition revision from the template or the current revision, and creates an AppSpec for it.
+func (f *FlagContext) registerECSAppSpec(ctx context.Context, ecsClient deploy.ECSClient, fileReader deploy.FileReader) (*deploy.AppSpec, error) {
+	var input *ecs.RegisterTaskDefinitionInput
+
+	if *f.taskDefinitionTemplate != "" {
+		data, err := fileReader(*f.taskDefinitionTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file: %w", err)
+		}
+
+		if input, err = deploy.ParseTaskDefinitionTemplate(data); err != nil {
+			return nil, fmt.Errorf("cannot parse file %q: %w", *f.taskDefinitionTemplate, err)
+		}
+	} else {
+		var err error
+		if input, err = deploy.CurrentTaskDefinitionInput(ctx, ecsClient, *f.taskDefinitionARN); err != nil {
+			return nil, err
+		}
 	}
 
-	log.Print("deployment finished successfully")
+	if *f.image != "" {
+		if err := deploy.SetContainerImage(input, *f.containerName, *f.image); err != nil {
+			return nil, err
+		}
+	}
+
+	taskDefinition, err := deploy.RegisterTaskDefinition(ctx, ecsClient, input)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("registered task definition %q", *taskDefinition.TaskDefinitionArn)
+
+	return deploy.NewECSFromRegisteredTaskDefinition(taskDefinition, *f.containerName, *f.containerPort, f.ecsOptions()...)
+}
+
+func (f *FlagContext) newECSAppSpec(ctx context.Context, clients *awsClients, fileReader deploy.FileReader) (*deploy.AppSpec, error) {
+	if *f.taskDefinitionTemplate == "" && *f.image == "" && strings.HasPrefix(*f.taskDefinitionARN, "arn:") && *f.containerName != "" && *f.containerPort != 0 {
+		return deploy.NewECS(*f.taskDefinitionARN, *f.containerName, *f.containerPort, f.ecsOptions()...), nil
+	}
+
+	ecsClient, err := clients.ecs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if *f.taskDefinitionTemplate != "" || *f.image != "" {
+		return f.registerECSAppSpec(ctx, ecsClient, fileReader)
+	}
+
+	appSpec, err := deploy.NewECSFromTaskDefinition(ctx, ecsClient, *f.taskDefinitionARN, *f.containerName, *f.containerPort, f.ecsOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := appSpec.Resources[0].TargetService.Properties.(deploy.ECSProperties)
+	log.Printf("resolved task definition %q (container %q, port %d)", properties.TaskDefinition, properties.LoadBalancerInfo.ContainerName, properties.LoadBalancerInfo.ContainerPort)
+
+	return appSpec, nil
+}
+
+func (f *FlagContext) newLambdaAppSpec(ctx context.Context, clients *awsClients) (*deploy.AppSpec, error) {
+	if *f.currentVersion != "" && !*f.publishVersion {
+		return deploy.NewLambda(*f.functionName, *f.functionAlias, *f.currentVersion, *f.targetVersion), nil
+	}
+
+	lambdaClient, err := clients.lambda(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	targetVersion := *f.targetVersion
+
+	if *f.publishVersion {
+		if targetVersion, err = deploy.PublishVersion(ctx, lambdaClient, *f.functionName, *f.codeSha256); err != nil {
+			return nil, err
+		}
+
+		log.Printf("published version %q of function %q", targetVersion, *f.functionName)
+	}
+
+	if *f.currentVersion != "" {
+		return deploy.NewLambda(*f.functionName, *f.functionAlias, *f.currentVersion, targetVersion), nil
+	}
+
+	appSpec, err := deploy.NewLambdaFromAlias(ctx, lambdaClient, *f.functionName, *f.functionAlias, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("resolved current version of function %q from alias %q", *f.functionName, *f.functionAlias)
+
+	return appSpec, nil
+}
+
+// uploadServerBundle bundles bundleDirectory, uploads the bundle to S3 and registers it as revision of the application.
+func (f *FlagContext) uploadServerBundle(ctx context.Context, s3Client deploy.S3Client, codeDeployClient deploy.CodeDeployClient) (deploy.S3Revision, error) {
+	bundleType := types.BundleType(*f.s3BundleType)
+
+	bundle, err := os.CreateTemp("", "codedeploy-trigger-bundle-*")
+	if err != nil {
+		return deploy.S3Revision{}, fmt.Errorf("cannot create bundle file: %w", err)
+	}
+	defer os.Remove(bundle.Name())
+	defer bundle.Close()
+
+	if err := deploy.BundleDirectory(bundle, *f.bundleDirectory, bundleType); err != nil {
+		return deploy.S3Revision{}, err
+	}
+
+	if _, err := bundle.Seek(0, io.SeekStart); err != nil {
+		return deploy.S3Revision{}, fmt.Errorf("cannot read bundle file: %w", err)
+	}
+
+	revision, err := deploy.UploadBundle(ctx, s3Client, bundle, *f.s3Bucket, *f.s3Key, bundleType)
+	if err != nil {
+		return deploy.S3Revision{}, err
+	}
+
+	log.Printf("uploaded bundle of directory %q to \"s3://%s/%s\" (ETag %q)", *f.bundleDirectory, revision.Bucket, revision.Key, revision.ETag)
+
+	if err := deploy.RegisterApplicationRevision(ctx, codeDeployClient, *f.applicationName, revision); err != nil {
+		return deploy.S3Revision{}, err
+	}
+
+	log.Printf("registered revision with application %q", *f.applicationName)
+
+	return revision, nil
+}
+
+// loadRevision sets the S3 or GitHub revision, the uploaded bundle of target "Server", or the AppSpec from the file or the target flags.
+func (f *FlagContext) loadRevision(ctx context.Context, codeDeployContext *deploy.CodeDeployContext, clients *awsClients) error {
+	if *f.target == ServerTarget {
+		s3Client, err := clients.s3(ctx)
+		if err != nil {
+			return err
+		}
+
+		revision, err := f.uploadServerBundle(ctx, s3Client, codeDeployContext.Client)
+		if err != nil {
+			return err
+		}
+
+		codeDeployContext.WithRevision(revision)
+		return nil
+	}
+
+	if revisionSource := f.revisionSource(); revisionSource != nil {
+		codeDeployContext.WithRevision(revisionSource)
+		return nil
+	}
+
+	return f.loadAppSpec(ctx, codeDeployContext, clients)
+}
+
+// loadAppSpec sets the AppSpec from the file or the target flags.
+func (f *FlagContext) loadAppSpec(ctx context.Context, codeDeployContext *deploy.CodeDeployContext, clients *awsClients) error {
+	if *f.appSpecFileName != "" {
+		_, err := codeDeployContext.WithAppSpecFile(*f.appSpecFileName)
+		return err
+	}
+
+	var appSpec *deploy.AppSpec
+	var err error
+
+	if *f.target == ECSTarget {
+		if appSpec, err = f.newECSAppSpec(ctx, clients, codeDeployContext.FileReader); err != nil {
+			return err
+		}
+	}
+
+	if *f.target == LambdaTarget {
+		if appSpec, err = f.newLambdaAppSpec(ctx, clients); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range f.hooks {
+		if err := appSpec.AddHook(hook.Name, hook.FunctionName); err != nil {
+			return err
+		}
+	}
+
+	_, err = codeDeployContext.WithAppSpec(appSpec)
+	return err
+}
+
+func parseCommand(arguments []string) (string, []string) {
+	if len(arguments) > 0 && (arguments[0] == ValidateCommand || arguments[0] == WaitCommand || arguments[0] == ContinueCommand) {
+		return arguments[0], arguments[1:]
+	}
+	return DeployCommand, arguments
+}
+
+// logProblems logs each error joined by errors.Join on a separate line.
+func logProblems(err error) {
+	var joinedErr interface{ Unwrap() []error }
+	if !errors.As(err, &joinedErr) {
+		log.Printf("- %s", err)
+		return
+	}
+
+	for _, problem := range joinedErr.Unwrap() {
+		log.Printf("- %s", problem)
+	}
+}
+
+func runValidate(flagContext *FlagContext) {
+	codeDeployContext := &deploy.CodeDeployContext{FileReader: os.ReadFile}
+
+	if err := flagContext.loadAppSpec(context.Background(), codeDeployContext, &awsClients{}); err != nil {
+		fatal(err)
+	}
+
+	if err := codeDeployContext.AppSpec().Validate(); err != nil {
+		log.Print("AppSpec is invalid:")
+		logProblems(err)
+		os.Exit(ExitError)
+	}
+
+	log.Print("AppSpec is valid")
+}
+
+type renderedAppSpecContent struct {
+	Content json.RawMessage `json:"content"`
+	Sha256  string          `json:"sha256,omitempty"`
+}
+
+type renderedS3Location struct {
+	Bucket     string           `json:"bucket"`
+	Key        string           `json:"key"`
+	BundleType types.BundleType `json:"bundleType,omitempty"`
+	Version    string           `json:"version,omitempty"`
+	ETag       string           `json:"eTag,omitempty"`
+}
+
+type renderedGitHubLocation struct {
+	Repository string `json:"repository"`
+	CommitID   string `json:"commitId"`
+}
+
+type renderedRevisionLocation struct {
+	RevisionType   types.RevisionLocationType `json:"revisionType"`
+	AppSpecContent *renderedAppSpecContent    `json:"appSpecContent,omitempty"`
+	S3Location     *renderedS3Location        `json:"s3Location,omitempty"`
+	GitHubLocation *renderedGitHubLocation    `json:"gitHubLocation,omitempty"`
+}
+
+type renderedAutoRollbackConfiguration struct {
+	Enabled bool                      `json:"enabled"`
+	Events  []types.AutoRollbackEvent `json:"events,omitempty"`
+}
+
+type renderedAlarm struct {
+	Name string `json:"name"`
+}
+
+type renderedAlarmConfiguration struct {
+	Enabled bool            `json:"enabled"`
+	Alarms  []renderedAlarm `json:"alarms,omitempty"`
+}
+
+// renderedCreateDeploymentInput mirrors the CreateDeployment API request, leaving out unset fields.
+type renderedCreateDeploymentInput struct {
+	ApplicationName               string                             `json:"applicationName"`
+	DeploymentGroupName           string                             `json:"deploymentGroupName"`
+	Revision                      *renderedRevisionLocation          `json:"revision,omitempty"`
+	DeploymentConfigName          string                             `json:"deploymentConfigName,omitempty"`
+	Description                   string                             `json:"description,omitempty"`
+	IgnoreApplicationStopFailures bool                               `json:"ignoreApplicationStopFailures,omitempty"`
+	AutoRollbackConfiguration     *renderedAutoRollbackConfiguration `json:"autoRollbackConfiguration,omitempty"`
+	UpdateOutdatedInstancesOnly   bool                               `json:"updateOutdatedInstancesOnly,omitempty"`
+	FileExistsBehavior            types.FileExistsBehavior           `json:"fileExistsBehavior,omitempty"`
+	OverrideAlarmConfiguration    *renderedAlarmConfiguration        `json:"overrideAlarmConfiguration,omitempty"`
+}
+
+// renderRevisionLocation converts the revision, embedding the AppSpec content as JSON rather than as string.
+func renderRevisionLocation(revision *types.RevisionLocation) *renderedRevisionLocation {
+	rendered := &renderedRevisionLocation{RevisionType: revision.RevisionType}
+
+	if appSpecContent := revision.AppSpecContent; appSpecContent != nil && appSpecContent.Content != nil {
+		rendered.AppSpecContent = &renderedAppSpecContent{
+			Content: json.RawMessage(*appSpecContent.Content),
+			Sha256:  aws.ToString(appSpecContent.Sha256),
+		}
+	}
+
+	if s3Location := revision.S3Location; s3Location != nil {
+		rendered.S3Location = &renderedS3Location{
+			Bucket:     aws.ToString(s3Location.Bucket),
+			Key:        aws.ToString(s3Location.Key),
+			BundleType: s3Location.BundleType,
+			Version:    aws.ToString(s3Location.Version),
+			ETag:       aws.ToString(s3Location.ETag),
+		}
+	}
+
+	if gitHubLocation := revision.GitHubLocation; gitHubLocation != nil {
+		rendered.GitHubLocation = &renderedGitHubLocation{
+			Repository: aws.ToString(gitHubLocation.Repository),
+			CommitID:   aws.ToString(gitHubLocation.CommitId),
+		}
+	}
+
+	return rendered
+}
+
+// renderCreateDeploymentInput marshals the request in the shape of the CreateDeployment API request.
+func renderCreateDeploymentInput(input *codedeploy.CreateDeploymentInput) ([]byte, error) {
+	rendered := renderedCreateDeploymentInput{
+		ApplicationName:               aws.ToString(input.ApplicationName),
+		DeploymentGroupName:           aws.ToString(input.DeploymentGroupName),
+		DeploymentConfigName:          aws.ToString(input.DeploymentConfigName),
+		Description:                   aws.ToString(input.Description),
+		IgnoreApplicationStopFailures: input.IgnoreApplicationStopFailures,
+		UpdateOutdatedInstancesOnly:   input.UpdateOutdatedInstancesOnly,
+		FileExistsBehavior:            input.FileExistsBehavior,
+	}
+
+	if input.Revision != nil {
+		rendered.Revision = renderRevisionLocation(input.Revision)
+	}
+
+	if autoRollbackConfiguration := input.AutoRollbackConfiguration; autoRollbackConfiguration != nil {
+		rendered.AutoRollbackConfiguration = &renderedAutoRollbackConfiguration{
+			Enabled: autoRollbackConfiguration.Enabled,
+			Events:  autoRollbackConfiguration.Events,
+		}
+	}
+
+	if alarmConfiguration := input.OverrideAlarmConfiguration; alarmConfiguration != nil {
+		rendered.OverrideAlarmConfiguration = &renderedAlarmConfiguration{Enabled: alarmConfiguration.Enabled}
+		for _, alarm := range alarmConfiguration.Alarms {
+			rendered.OverrideAlarmConfiguration.Alarms = append(rendered.OverrideAlarmConfiguration.Alarms, renderedAlarm{Name: aws.ToString(alarm.Name)})
+		}
+	}
+
+	return json.MarshalIndent(rendered, "", "  ")
+}
+
+func runDryRun(flagContext *FlagContext) {
+	codeDeployContext := &deploy.CodeDeployContext{FileReader: os.ReadFile}
+
+	if err := flagContext.loadRevision(context.Background(), codeDeployContext, &awsClients{}); err != nil {
+		fatal(err)
+	}
+
+	options, err := flagContext.deploymentOptions()
+	if err != nil {
+		fatal(err)
+	}
+
+	input, err := codeDeployContext.CreateDeploymentInput(*flagContext.applicationName, *flagContext.deploymentGroupName, options...)
+	if err != nil {
+		fatal(err)
+	}
+
+	rendered, err := renderCreateDeploymentInput(input)
+	if err != nil {
+		fatal(fmt.Errorf("cannot render deployment: %w", err))
+	}
+
+	fmt.Println(string(rendered))
+}
+
+// stopPolicy returns the policy for the reason why waiting for the deployment ended.
+// Deployments which finished on their own are left alone.
+func (f *FlagContext) stopPolicy(waitErr error) string {
+	switch {
+	case errors.Is(waitErr, deploy.ErrWaitTimeout):
+		return *f.onTimeout
+	case errors.Is(waitErr, context.Canceled):
+		return *f.onInterrupt
+	case errors.Is(waitErr, errVerificationFailed):
+		return StopAndRollbackPolicy
+	}
+	return LeavePolicy
+}
+
+// stopDeployment stops the deployment according to the policy and reports whether CodeDeploy confirmed the stop.
+func stopDeployment(poller *deploy.DeploymentPoller, deploymentID string, policy string) bool {
+	log.Printf("stopping deployment ID %q (%s)", deploymentID, policy)
+
+	if err := poller.Stop(context.Background(), deploymentID, policy == StopAndRollbackPolicy, stopWaitDuration); err != nil {
+		log.Printf("cannot stop deployment: %s", err)
+		return false
+	}
+
+	log.Printf("deployment ID %q stopped", deploymentID)
+	return true
+}
+
+func newCodeDeployContext(awsConfig aws.Config, flagContext *FlagContext) (*deploy.CodeDeployContext, *deploy.DeploymentPoller) {
+	codeDeployClient := codedeploy.NewFromConfig(awsConfig)
+	poller := deploy.NewDeploymentPoller(codeDeployClient, *flagContext.pollInterval, newDeploymentLogger().logEvent)
+	poller.ReportTargets = true
+	poller.ReadyHandler = flagContext.readyHandler(poller)
+	poller.ReturnWhenTrafficShifted = *flagContext.returnWhen == TrafficShiftedMilestone
+	poller.ContinueTerminationWait = *flagContext.terminateOriginal
+
+	return &deploy.CodeDeployContext{
+		Client:                     codeDeployClient,
+		DeploymentSuccessfulWaiter: poller.Wait,
+		FileReader:                 os.ReadFile,
+	}, poller
+}
+
+// waitForDeployment exits with the exit code of the outcome, unless the deployment succeeded.
+// stopSignals is called as soon as waiting ended, so that another signal terminates immediately.
+func waitForDeployment(ctx context.Context, stopSignals context.CancelFunc, flagContext *FlagContext, codeDeployContext *deploy.CodeDeployContext, poller *deploy.DeploymentPoller, deploymentID string) {
+	log.Printf("waiting for deployment ID %q to finish", deploymentID)
+
+	err := codeDeployContext.WaitForSuccessfulDeployment(ctx, deploymentID, *flagContext.maxWaitDuration)
+
+	var failedErr *deploy.DeploymentFailedError
+	if errors.As(err, &failedErr) && failedErr.RollbackDeploymentID() != "" {
+		followRollback(ctx, stopSignals, flagContext, codeDeployContext, poller, failedErr)
+	}
+
+	stopSignals()
+
+	if errors.Is(err, errDeploymentReady) {
+		log.Printf("deployment ID %q is ready to reroute traffic, run \"codedeploy-trigger continue -deploymentId %s\" to continue", deploymentID, deploymentID)
+		os.Exit(exitCode(err))
+	}
+
+	if err != nil {
+		log.Printf("deployment failed: %s", err)
+		logDeploymentTargets(context.Background(), codeDeployContext.Client, deploymentID)
+
+		if failedErr != nil && failedErr.RollbackInfo != nil && failedErr.RollbackInfo.RollbackMessage != nil {
+			log.Printf("rollback: %s", *failedErr.RollbackInfo.RollbackMessage)
+		}
+
+		if policy := flagContext.stopPolicy(err); policy != LeavePolicy && stopDeployment(poller, deploymentID, policy) {
+			os.Exit(ExitDeploymentStopped)
+		}
+
+		os.Exit(exitCode(err))
+	}
+
+	if *flagContext.returnWhen == TrafficShiftedMilestone {
+		log.Print("deployment shifted traffic successfully")
+		return
+	}
+
+	log.Print("deployment finished successfully")
+}
+
+// followRollback waits for the rollback deployment of the failed deployment, and exits with the exit code of the outcome.
+// The rollback is never stopped, continued or verified, and it is awaited until it finished regardless of returnWhen.
+func followRollback(ctx context.Context, stopSignals context.CancelFunc, flagContext *FlagContext, codeDeployContext *deploy.CodeDeployContext, poller *deploy.DeploymentPoller, failedErr *deploy.DeploymentFailedError) {
+	log.Printf("deployment failed: %s", failedErr)
+	logDeploymentTargets(context.Background(), codeDeployContext.Client, failedErr.DeploymentID)
+
+	if failedErr.RollbackInfo.RollbackMessage != nil {
+		log.Printf("rollback: %s", *failedErr.RollbackInfo.RollbackMessage)
+	}
+
+	rollbackDeploymentID := failedErr.RollbackDeploymentID()
+	log.Printf("waiting for rollback deployment ID %q to finish", rollbackDeploymentID)
+
+	rollbackContext := deploy.NewCodeDeployContext(codeDeployContext.Client, poller.WithoutHandlers().Wait, codeDeployContext.FileReader)
+	err := rollbackContext.WaitForRollback(ctx, failedErr, *flagContext.maxWaitDuration)
+	stopSignals()
+
+	if errors.Is(err, deploy.ErrRollbackFailed) {
+		log.Printf("deployment failed and rollback failed too: %s", err)
+		logDeploymentTargets(context.Background(), codeDeployContext.Client, rollbackDeploymentID)
+		os.Exit(exitCode(err))
+	}
+
+	if err != nil {
+		log.Printf("cannot follow rollback: %s", err)
+		os.Exit(exitCode(err))
+	}
+
+	log.Print("deployment failed and was rolled back successfully")
+	os.Exit(ExitRolledBack)
+}
+
+// createDeployment creates the deployment and handles an active deployment of the deployment group according to whenActive.
+// It retries until no other deployment is active or maxWaitDuration elapsed. Deployments which become active in the meantime,
+// e.g. the rollback of a stopped deployment, are waited for instead of being stopped too.
+// If the active deployment is attached to, its ID is returned instead.
+func (f *FlagContext) createDeployment(ctx context.Context, codeDeployContext *deploy.CodeDeployContext, poller *deploy.DeploymentPoller) (string, error) {
+	options, err := f.deploymentOptions()
+	if err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(*f.maxWaitDuration)
+	policy := *f.whenActive
+
+	for {
+		deploymentID, err := codeDeployContext.CreateDeployment(ctx, *f.applicationName, *f.deploymentGroupName, options...)
+
+		var limitExceededErr *types.DeploymentLimitExceededException
+		if !errors.As(err, &limitExceededErr) || policy == FailPolicy {
+			if err != nil {
+				return "", err
+			}
+
+			log.Printf("deployment ID %q created", deploymentID)
+			return deploymentID, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", fmt.Errorf("%w of %s: %w", deploy.ErrWaitTimeout, *f.maxWaitDuration, err)
+		}
+
+		attachedDeploymentID, activeErr := f.handleActiveDeployment(ctx, codeDeployContext, poller, policy, remaining)
+		if errors.Is(activeErr, deploy.ErrNoActiveDeployment) {
+			// The active deployment finished in the meantime.
+			if err := sleep(ctx, *f.pollInterval); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if activeErr != nil || attachedDeploymentID != "" {
+			return attachedDeploymentID, activeErr
+		}
+
+		if policy == StopPolicy || policy == StopAndRollbackPolicy {
+			policy = WaitPolicy
+		}
+	}
+}
+
+// sleep waits for the duration, or returns the error of the context if it ended earlier.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// findUnchangedDeployment returns the latest deployment of the deployment group within skipIfUnchanged, if it deployed the same AppSpec.
+// It returns nil if skipIfUnchanged is disabled.
+func (f *FlagContext) findUnchangedDeployment(ctx context.Context, codeDeployContext *deploy.CodeDeployContext) (*types.DeploymentInfo, error) {
+	if *f.skipIfUnchanged == 0 {
+		return nil, nil
+	}
+
+	return deploy.FindUnchangedDeployment(ctx, codeDeployContext.Client, *f.applicationName, *f.deploymentGroupName, codeDeployContext.AppSpecSha256(), time.Now().Add(-*f.skipIfUnchanged))
+}
+
+// handleActiveDeployment waits for or stops the active deployment according to the policy, or returns its ID if it is attached to.
+func (f *FlagContext) handleActiveDeployment(ctx context.Context, codeDeployContext *deploy.CodeDeployContext, poller *deploy.DeploymentPoller, policy string, maxWaitDur time.Duration) (string, error) {
+	activeDeploymentID, err := deploy.FindActiveDeployment(ctx, codeDeployContext.Client, *f.applicationName, *f.deploymentGroupName)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("deployment ID %q is already active", activeDeploymentID)
+
+	switch policy {
+	case AttachPolicy:
+		activeDeployment, err := deploy.GetDeployment(ctx, codeDeployContext.Client, activeDeploymentID)
+		if err != nil {
+			return "", err
+		}
+
+		if deploy.RevisionAppSpecSha256(activeDeployment) != codeDeployContext.AppSpecSha256() {
+			return "", fmt.Errorf("cannot attach to deployment ID %q, because its AppSpec differs", activeDeploymentID)
+		}
+
+		log.Printf("attaching to deployment ID %q", activeDeploymentID)
+		return activeDeploymentID, nil
+	case WaitPolicy:
+		log.Printf("waiting for deployment ID %q to finish", activeDeploymentID)
+		return "", poller.WaitUntilFinished(ctx, activeDeploymentID, maxWaitDur)
+	case StopPolicy, StopAndRollbackPolicy:
+		log.Printf("stopping deployment ID %q (%s)", activeDeploymentID, policy)
+		return "", poller.Stop(ctx, activeDeploymentID, policy == StopAndRollbackPolicy, stopWaitDuration)
+	}
+
+	return "", nil
+}
+
+func runDeploy(flagContext *FlagContext) {
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	clients := &awsClients{}
+
+	awsConfig, err := clients.config(ctx)
+	if err != nil {
+		fatal(err)
+	}
+
+	codeDeployContext, poller := newCodeDeployContext(awsConfig, flagContext)
+
+	log.Printf("creating deployment for application %q (group %q)", *flagContext.applicationName, *flagContext.deploymentGroupName)
+
+	if err := flagContext.loadRevision(ctx, codeDeployContext, clients); err != nil {
+		fatal(err)
+	}
+
+	unchangedDeployment, err := flagContext.findUnchangedDeployment(ctx, codeDeployContext)
+	if err != nil {
+		fatal(err)
+	}
+
+	if unchangedDeployment != nil {
+		unchangedDeploymentID := aws.ToString(unchangedDeployment.DeploymentId)
+
+		if unchangedDeployment.Status == types.DeploymentStatusSucceeded {
+			log.Printf("skipping deployment, because deployment ID %q already deployed this AppSpec successfully", unchangedDeploymentID)
+			return
+		}
+
+		log.Printf("reusing deployment ID %q, which is already deploying this AppSpec", unchangedDeploymentID)
+		waitForDeployment(ctx, stopSignals, flagContext, codeDeployContext, poller, unchangedDeploymentID)
+		return
+	}
+
+	deploymentID, err := flagContext.createDeployment(ctx, codeDeployContext, poller)
+	if err != nil {
+		log.Print(err)
+		if code := exitCode(err); code != ExitError {
+			os.Exit(code)
+		}
+		os.Exit(ExitCreateFailed)
+	}
+
+	waitForDeployment(ctx, stopSignals, flagContext, codeDeployContext, poller, deploymentID)
+}
+
+// runWait follows an existing deployment. The continue command reroutes its traffic first.
+func runWait(flagContext *FlagContext) {
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	awsConfig, err := (&awsClients{}).config(ctx)
+	if err != nil {
+		fatal(err)
+	}
+
+	codeDeployContext, poller := newCodeDeployContext(awsConfig, flagContext)
+
+	deploymentID := *flagContext.deploymentID
+	if deploymentID == "" {
+		if deploymentID, err = deploy.FindActiveDeployment(ctx, codeDeployContext.Client, *flagContext.applicationName, *flagContext.deploymentGroupName); err != nil {
+			fatal(err)
+		}
+
+		log.Printf("found active deployment ID %q for application %q (group %q)", deploymentID, *flagContext.applicationName, *flagContext.deploymentGroupName)
+	}
+
+	if flagContext.Command == ContinueCommand {
+		if err := poller.Continue(ctx, deploymentID, types.DeploymentWaitTypeReadyWait); err != nil {
+			fatal(err)
+		}
+
+		log.Printf("rerouting traffic of deployment ID %q", deploymentID)
+	}
+
+	waitForDeployment(ctx, stopSignals, flagContext, codeDeployContext, poller, deploymentID)
+}
+
+func main() {
+	command, arguments := parseCommand(os.Args[1:])
+
+	flagContext := &FlagContext{FlagSet: flag.CommandLine, Command: command}
+	if command != DeployCommand {
+		flagContext.FlagSet = flag.NewFlagSet(fmt.Sprintf("%s %s", os.Args[0], command), flag.ExitOnError)
+	}
+
+	if err := flagContext.Parse(arguments); err != nil {
+		log.Println(err)
+		os.Exit(ExitUsage)
+	}
+
+	switch {
+	case command == ValidateCommand:
+		runValidate(flagContext)
+	case command == WaitCommand || command == ContinueCommand:
+		runWait(flagContext)
+	case *flagContext.dryRun:
+		runDryRun(flagContext)
+	default:
+		runDeploy(flagContext)
+	}
 }
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
 	"testing"
 	"time"
 )
@@ -32,6 +38,14 @@ func Test_checkTarget(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Server target",
+			args: args{
+				flagName:  "test",
+				flagValue: ServerTarget,
+			},
+			wantErr: false,
+		},
 		{
 			name: "Unknown target",
 			args: args{
@@ -174,11 +188,153 @@ func Test_checkDuration(t *testing.T) {
 	}
 }
 
+func Test_checkAssignPublicIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		flagValue string
+		wantErr   bool
+	}{
+		{name: "unset", flagValue: "", wantErr: false},
+		{name: "enabled", flagValue: "ENABLED", wantErr: false},
+		{name: "disabled", flagValue: "DISABLED", wantErr: false},
+		{name: "unknown", flagValue: "enabled", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkAssignPublicIP("test", tt.flagValue); (err != nil) != tt.wantErr {
+				t.Errorf("checkAssignPublicIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_capacityProviderStrategyFlags_Set(t *testing.T) {
+	var capacityProviders capacityProviderStrategyFlags
+
+	if err := capacityProviders.Set("FARGATE:1:2,FARGATE_SPOT:3"); err != nil {
+		t.Error("unexpected error")
+	}
+
+	if capacityProviders.String() != "FARGATE:1:2,FARGATE_SPOT:3:0" {
+		t.Error("unexpected capacity provider strategy")
+	}
+
+	for _, value := range []string{"FARGATE", ":1", "FARGATE:x", "FARGATE:1001", "FARGATE:1:-1", "FARGATE:1:2:3"} {
+		if err := capacityProviders.Set(value); err == nil {
+			t.Errorf("no error for %q", value)
+		}
+	}
+}
+
+func TestFlagContext_Parse_ECSNetworking(t *testing.T) {
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+	arguments := []string{
+		"-applicationName", "my-app",
+		"-deploymentGroupName", "my-group",
+		"-target", "ECS",
+		"-taskDefinitionARN", "my-task-def",
+		"-containerName", "my-container",
+		"-containerPort", "1337",
+		"-platformVersion", "LATEST",
+		"-subnets", "subnet-1,subnet-2",
+		"-securityGroups", "sg-1",
+		"-assignPublicIp", "ENABLED",
+		"-capacityProviderStrategy", "FARGATE_SPOT:1",
+	}
+
+	if err := flagContext.Parse(arguments); err != nil {
+		t.Error("unexpected error")
+	}
+
+	if options := flagContext.ecsOptions(); len(options) != 3 {
+		t.Error("unexpected ECS options")
+	}
+}
+
+func TestFlagContext_Parse_securityGroupsWithoutSubnets(t *testing.T) {
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+	arguments := []string{
+		"-applicationName", "my-app",
+		"-deploymentGroupName", "my-group",
+		"-target", "ECS",
+		"-taskDefinitionARN", "my-task-def",
+		"-containerName", "my-container",
+		"-containerPort", "1337",
+		"-securityGroups", "sg-1",
+	}
+
+	if err := flagContext.Parse(arguments); err == nil {
+		t.Error("no error")
+	}
+}
+
+func Test_checkHooks(t *testing.T) {
+	type args struct {
+		target string
+		hooks  []deploy.Hook
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "ECS hook",
+			args: args{
+				target: ECSTarget,
+				hooks:  []deploy.Hook{{Name: deploy.AfterAllowTestTrafficHook, FunctionName: "validate"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Lambda hook",
+			args: args{
+				target: LambdaTarget,
+				hooks:  []deploy.Hook{{Name: deploy.BeforeAllowTrafficHook, FunctionName: "validate"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported Lambda hook",
+			args: args{
+				target: LambdaTarget,
+				hooks:  []deploy.Hook{{Name: deploy.AfterAllowTestTrafficHook, FunctionName: "validate"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkHooks("test", tt.args.target, tt.args.hooks); (err != nil) != tt.wantErr {
+				t.Errorf("checkHooks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_hookFlags_Set(t *testing.T) {
+	var hooks hookFlags
+
+	if err := hooks.Set("AfterAllowTraffic=arn:aws:lambda:eu-central-1:123456789012:function:validate"); err != nil {
+		t.Error("unexpected error")
+	}
+
+	if len(hooks) != 1 || hooks[0].Name != deploy.AfterAllowTrafficHook || hooks[0].FunctionName != "arn:aws:lambda:eu-central-1:123456789012:function:validate" {
+		t.Error("unexpected hook")
+	}
+
+	if err := hooks.Set("AfterAllowTraffic"); err == nil {
+		t.Error("no error")
+	}
+}
+
 func TestFlagContext_Parse(t *testing.T) {
 	flagContext := &FlagContext{FlagSet: flag.CommandLine}
 	arguments := []string{
 		"-maxWaitDuration",
 		"15m",
+		"-pollInterval",
+		"5s",
 		"-applicationName",
 		"my-app",
 		"-deploymentGroupName",
@@ -191,8 +347,6 @@ func TestFlagContext_Parse(t *testing.T) {
 		"my-task-def",
 		"-containerName",
 		"my-container",
-		"-containerPort",
-		"1337",
 		"-functionName",
 		"my-function",
 		"-functionAlias",
@@ -211,6 +365,10 @@ func TestFlagContext_Parse(t *testing.T) {
 		t.Error("unexpected max wait duration")
 	}
 
+	if flagContext.pollInterval.String() != "5s" {
+		t.Error("unexpected poll interval")
+	}
+
 	if *flagContext.applicationName != "my-app" {
 		t.Error("unexpected application name")
 	}
@@ -235,10 +393,6 @@ func TestFlagContext_Parse(t *testing.T) {
 		t.Error("unexpected container name")
 	}
 
-	if *flagContext.containerPort != 1337 {
-		t.Error("unexpected container Port")
-	}
-
 	if *flagContext.functionName != "my-function" {
 		t.Error("unexpected function name")
 	}
@@ -254,3 +408,572 @@ func TestFlagContext_Parse(t *testing.T) {
 		t.Error("unexpected target version")
 	}
 }
+
+func TestFlagContext_Parse_appSpecFileName(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		wantErr   bool
+	}{
+		{
+			name:      "AppSpec file",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-target", "ECS", "-taskDefinitionARN", "my-task-def"},
+			wantErr:   false,
+		},
+		{
+			name:      "hook",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-hook", "BeforeAllowTraffic=my-before-hook"},
+			wantErr:   true,
+		},
+		{
+			name:      "container port",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-containerPort", "1337"},
+			wantErr:   true,
+		},
+		{
+			name:      "subnets",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-subnets", "subnet-1"},
+			wantErr:   true,
+		},
+		{
+			name:      "capacity provider strategy",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-capacityProviderStrategy", "FARGATE_SPOT:1"},
+			wantErr:   true,
+		},
+		{
+			name:      "published version",
+			arguments: []string{"-appSpecFileName", "appspec.yml", "-publishVersion"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+			arguments := append([]string{"-applicationName", "my-app", "-deploymentGroupName", "my-group"}, tt.arguments...)
+
+			if err := flagContext.Parse(arguments); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseCommand(t *testing.T) {
+	command, arguments := parseCommand([]string{"validate", "-appSpecFileName", "appspec.yml"})
+	if command != ValidateCommand || len(arguments) != 2 {
+		t.Error("unexpected validate command")
+	}
+
+	command, arguments = parseCommand([]string{"-applicationName", "my-app"})
+	if command != DeployCommand || len(arguments) != 2 {
+		t.Error("unexpected deploy command")
+	}
+}
+
+func TestFlagContext_Parse_validateCommand(t *testing.T) {
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: ValidateCommand}
+
+	if err := flagContext.Parse([]string{"-appSpecFileName", "appspec.yml"}); err != nil {
+		t.Error("unexpected error")
+	}
+}
+
+func Test_renderCreateDeploymentInput(t *testing.T) {
+	codeDeployContext, _ := (&deploy.CodeDeployContext{}).WithAppSpec(deploy.NewLambda("function-name", "function-alias", "42", "43"))
+	input, _ := codeDeployContext.CreateDeploymentInput("my-app", "my-group")
+
+	rendered, err := renderCreateDeploymentInput(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		ApplicationName string
+		Revision        struct {
+			RevisionType   string
+			AppSpecContent struct {
+				Content deploy.AppSpec
+				Sha256  string
+			}
+		}
+	}
+	if err := json.Unmarshal(rendered, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ApplicationName != "my-app" || result.Revision.RevisionType != "AppSpecContent" || result.Revision.AppSpecContent.Sha256 != *input.Revision.AppSpecContent.Sha256 {
+		t.Error("unexpected rendered input")
+	}
+
+	if properties, ok := result.Revision.AppSpecContent.Content.Resources[0].TargetService.Properties.(deploy.LambdaProperties); !ok || properties.TargetVersion != "43" {
+		t.Error("unexpected rendered AppSpec")
+	}
+
+	if !bytes.Contains(rendered, []byte(`"applicationName": "my-app"`)) || bytes.Contains(rendered, []byte("null")) || bytes.Contains(rendered, []byte(`""`)) {
+		t.Errorf("rendered input doesn't match the API request: %s", rendered)
+	}
+}
+
+func TestFlagContext_Parse_publishVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		arguments []string
+		wantErr   bool
+	}{
+		{
+			name:      "publish",
+			arguments: []string{"-publishVersion", "-codeSha256", "c29tZS1oYXNo"},
+			wantErr:   false,
+		},
+		{
+			name:      "publish with target version",
+			arguments: []string{"-publishVersion", "-targetVersion", "43"},
+			wantErr:   true,
+		},
+		{
+			name:      "publish in dry run",
+			arguments: []string{"-publishVersion", "-dryRun"},
+			wantErr:   true,
+		},
+		{
+			name:      "publish in validate command",
+			command:   ValidateCommand,
+			arguments: []string{"-publishVersion"},
+			wantErr:   true,
+		},
+		{
+			name:      "code SHA256 without publish",
+			arguments: []string{"-targetVersion", "43", "-codeSha256", "c29tZS1oYXNo"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: tt.command}
+			arguments := append([]string{
+				"-applicationName", "my-app",
+				"-deploymentGroupName", "my-group",
+				"-target", "Lambda",
+				"-functionName", "my-function",
+				"-functionAlias", "my-alias",
+			}, tt.arguments...)
+
+			if err := flagContext.Parse(arguments); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlagContext_Parse_ECSTaskDefinitionFamily(t *testing.T) {
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+
+	if err := flagContext.Parse([]string{
+		"-applicationName", "my-app",
+		"-deploymentGroupName", "my-group",
+		"-target", "ECS",
+		"-taskDefinitionARN", "my-family:42",
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFlagContext_Parse_ECSRegisterTaskDefinition(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		arguments []string
+		wantErr   bool
+	}{
+		{
+			name:      "template",
+			arguments: []string{"-taskDefinitionTemplate", "task-definition.json", "-image", "my-image:2"},
+			wantErr:   false,
+		},
+		{
+			name:      "current revision",
+			arguments: []string{"-taskDefinitionARN", "my-family", "-image", "my-image:2"},
+			wantErr:   false,
+		},
+		{
+			name:      "template with task definition ARN",
+			arguments: []string{"-taskDefinitionTemplate", "task-definition.json", "-taskDefinitionARN", "my-family"},
+			wantErr:   true,
+		},
+		{
+			name:      "image in dry run",
+			arguments: []string{"-taskDefinitionARN", "my-family", "-image", "my-image:2", "-dryRun"},
+			wantErr:   true,
+		},
+		{
+			name:      "template in validate command",
+			command:   ValidateCommand,
+			arguments: []string{"-taskDefinitionTemplate", "task-definition.json"},
+			wantErr:   true,
+		},
+		{
+			name:      "image with skipIfUnchanged",
+			arguments: []string{"-taskDefinitionARN", "my-family", "-image", "my-image:2", "-skipIfUnchanged", "24h"},
+			wantErr:   true,
+		},
+		{
+			name:      "template with attach",
+			arguments: []string{"-taskDefinitionTemplate", "task-definition.json", "-whenActive", "attach"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: tt.command}
+			arguments := append([]string{
+				"-applicationName", "my-app",
+				"-deploymentGroupName", "my-group",
+				"-target", "ECS",
+			}, tt.arguments...)
+
+			if err := flagContext.Parse(arguments); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_checkPolicy(t *testing.T) {
+	for _, policy := range []string{LeavePolicy, StopPolicy, StopAndRollbackPolicy} {
+		if err := checkPolicy("onTimeout", policy); err != nil {
+			t.Errorf("unexpected error for %q", policy)
+		}
+	}
+
+	if err := checkPolicy("onTimeout", "rollback"); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestFlagContext_stopPolicy(t *testing.T) {
+	flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+	if err := flagContext.Parse([]string{
+		"-applicationName", "my-app",
+		"-deploymentGroupName", "my-group",
+		"-appSpecFileName", "appspec.yml",
+		"-onTimeout", "stop",
+		"-onInterrupt", "stop-and-rollback",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		waitErr error
+		want    string
+	}{
+		{
+			name:    "timeout",
+			waitErr: fmt.Errorf("%w of 1s: %w", deploy.ErrWaitTimeout, context.DeadlineExceeded),
+			want:    StopPolicy,
+		},
+		{
+			name:    "interrupt",
+			waitErr: fmt.Errorf("waiting for deployment aborted: %w", context.Canceled),
+			want:    StopAndRollbackPolicy,
+		},
+		{
+			name:    "failed",
+			waitErr: &deploy.DeploymentFailedError{},
+			want:    LeavePolicy,
+		},
+		{
+			name:    "verification failed",
+			waitErr: fmt.Errorf("%w: exit status 1", errVerificationFailed),
+			want:    StopAndRollbackPolicy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flagContext.stopPolicy(tt.waitErr); got != tt.want {
+				t.Errorf("stopPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagContext_Parse_waitCommand(t *testing.T) {
+	command, arguments := parseCommand([]string{"wait", "-deploymentId", "d-123"})
+	if command != WaitCommand || len(arguments) != 2 {
+		t.Fatal("unexpected wait command")
+	}
+
+	if command, _ := parseCommand([]string{"continue", "-deploymentId", "d-123"}); command != ContinueCommand {
+		t.Fatal("unexpected continue command")
+	}
+
+	tests := []struct {
+		name      string
+		arguments []string
+		wantErr   bool
+	}{
+		{
+			name:      "deployment ID",
+			arguments: []string{"-deploymentId", "d-123"},
+			wantErr:   false,
+		},
+		{
+			name:      "active deployment",
+			arguments: []string{"-applicationName", "my-app", "-deploymentGroupName", "my-group"},
+			wantErr:   false,
+		},
+		{
+			name:      "application without deployment group",
+			arguments: []string{"-applicationName", "my-app"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid policy",
+			arguments: []string{"-deploymentId", "d-123", "-onInterrupt", "rollback"},
+			wantErr:   true,
+		},
+		{
+			name:      "continue when ready",
+			arguments: []string{"-deploymentId", "d-123", "-whenReady", "continue", "-verifyCommand", "true"},
+			wantErr:   false,
+		},
+		{
+			name:      "invalid ready policy",
+			arguments: []string{"-deploymentId", "d-123", "-whenReady", "reroute"},
+			wantErr:   true,
+		},
+		{
+			name:      "return when traffic shifted",
+			arguments: []string{"-deploymentId", "d-123", "-returnWhen", "traffic-shifted", "-terminateOriginal"},
+			wantErr:   false,
+		},
+		{
+			name:      "invalid milestone",
+			arguments: []string{"-deploymentId", "d-123", "-returnWhen", "shifted"},
+			wantErr:   true,
+		},
+		{
+			name:      "verification without continue",
+			arguments: []string{"-deploymentId", "d-123", "-verifyCommand", "true"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: WaitCommand}
+
+			if err := flagContext.Parse(tt.arguments); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlagContext_Parse_deploymentOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		arguments   []string
+		wantOptions int
+		wantErr     bool
+	}{
+		{
+			name: "all options",
+			arguments: []string{
+				"-deploymentConfigName", "CodeDeployDefault.ECSAllAtOnce",
+				"-autoRollbackEvents", "DEPLOYMENT_FAILURE,DEPLOYMENT_STOP_ON_ALARM",
+				"-alarms", "alarm-1,alarm-2",
+				"-description", "hotfix",
+				"-fileExistsBehavior", "OVERWRITE",
+				"-ignoreApplicationStopFailures",
+				"-updateOutdatedInstancesOnly",
+			},
+			wantOptions: 7,
+		},
+		{
+			name:        "disabled",
+			arguments:   []string{"-disableAutoRollback", "-disableAlarms"},
+			wantOptions: 2,
+		},
+		{
+			name:      "unknown auto rollback event",
+			arguments: []string{"-autoRollbackEvents", "DEPLOYMENT_FAILURE,FAILURE"},
+			wantErr:   true,
+		},
+		{
+			name:      "disabled auto rollback with events",
+			arguments: []string{"-autoRollbackEvents", "DEPLOYMENT_FAILURE", "-disableAutoRollback"},
+			wantErr:   true,
+		},
+		{
+			name:      "disabled alarms with alarms",
+			arguments: []string{"-alarms", "alarm-1", "-disableAlarms"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid description template",
+			arguments: []string{"-description", "{{.CI.CommitSHA"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid file exists behavior",
+			arguments: []string{"-fileExistsBehavior", "REPLACE"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError)}
+			arguments := append([]string{
+				"-applicationName", "my-app",
+				"-deploymentGroupName", "my-group",
+				"-appSpecFileName", "appspec.yml",
+			}, tt.arguments...)
+
+			if err := flagContext.Parse(arguments); (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if options, err := flagContext.deploymentOptions(); err != nil || len(options) != tt.wantOptions {
+				t.Errorf("unexpected deployment options: %d, %v", len(options), err)
+			}
+		})
+	}
+}
+
+func TestFlagContext_Parse_revisionSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		arguments []string
+		want      deploy.RevisionSource
+		wantErr   bool
+	}{
+		{
+			name:      "S3",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.tgz", "-s3BundleType", "tgz", "-s3Version", "v1"},
+			want:      deploy.S3Revision{Bucket: "bucket", Key: "app.tgz", BundleType: types.BundleTypeTarGZip, Version: "v1"},
+		},
+		{
+			name:      "GitHub",
+			arguments: []string{"-gitHubRepository", "joeig/app", "-gitHubCommitId", "abc123"},
+			want:      deploy.GitHubRevision{Repository: "joeig/app", CommitID: "abc123"},
+		},
+		{
+			name:      "S3 without key",
+			arguments: []string{"-s3Bucket", "bucket"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid bundle type",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.rar", "-s3BundleType", "rar"},
+			wantErr:   true,
+		},
+		{
+			name:      "GitHub without commit",
+			arguments: []string{"-gitHubRepository", "joeig/app"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 and GitHub",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-gitHubRepository", "joeig/app", "-gitHubCommitId", "abc123"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 and AppSpec",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-appSpecFileName", "appspec.yml"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 and attach",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-whenActive", "attach"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 with task definition",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-taskDefinitionARN", "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1"},
+			wantErr:   true,
+		},
+		{
+			name:      "GitHub with function",
+			arguments: []string{"-gitHubRepository", "joeig/app", "-gitHubCommitId", "abc123", "-functionName", "app"},
+			wantErr:   true,
+		},
+		{
+			name:      "GitHub with published version",
+			arguments: []string{"-gitHubRepository", "joeig/app", "-gitHubCommitId", "abc123", "-publishVersion"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 with bundle directory",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-bundleDirectory", "app"},
+			wantErr:   true,
+		},
+		{
+			name:      "S3 dry run",
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip", "-dryRun"},
+			want:      deploy.S3Revision{Bucket: "bucket", Key: "app.zip", BundleType: types.BundleTypeZip},
+		},
+		{
+			name:      "validate S3",
+			command:   ValidateCommand,
+			arguments: []string{"-s3Bucket", "bucket", "-s3Key", "app.zip"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.tgz", "-s3BundleType", "tgz"},
+			want:      nil,
+		},
+		{
+			name:      "Server without bundle directory",
+			arguments: []string{"-target", "Server", "-s3Bucket", "bucket", "-s3Key", "app.zip"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server without bucket",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Key", "app.zip"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server with unsupported bundle type",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.tar", "-s3BundleType", "tar"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server with S3 version",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.zip", "-s3Version", "v1"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server with hook",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.zip", "-hook", "BeforeInstall=function"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server with task definition",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.zip", "-taskDefinitionARN", "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1"},
+			wantErr:   true,
+		},
+		{
+			name:      "Server dry run",
+			arguments: []string{"-target", "Server", "-bundleDirectory", "app", "-s3Bucket", "bucket", "-s3Key", "app.zip", "-dryRun"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: tt.command}
+			arguments := append([]string{"-applicationName", "my-app", "-deploymentGroupName", "my-group"}, tt.arguments...)
+
+			if err := flagContext.Parse(arguments); (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && flagContext.revisionSource() != tt.want {
+				t.Errorf("revisionSource() = %+v, want %+v", flagContext.revisionSource(), tt.want)
+			}
+		})
+	}
+}
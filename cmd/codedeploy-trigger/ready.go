@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// readyHandler returns the handler for deployments which are ready to reroute traffic according to whenReady.
+// It returns nil if the deployment group's wait time should elapse instead.
+func (f *FlagContext) readyHandler(poller *deploy.DeploymentPoller) deploy.DeploymentReadyHandler {
+	switch *f.whenReady {
+	case ContinuePolicy:
+		return func(ctx context.Context, event deploy.DeploymentEvent) error {
+			if *f.verifyCommand != "" {
+				log.Printf("deployment ID %q is ready to reroute traffic, running verification command", event.DeploymentID)
+
+				if err := runVerifyCommand(ctx, *f.verifyCommand, event.DeploymentID); err != nil {
+					return err
+				}
+			}
+
+			log.Printf("rerouting traffic of deployment ID %q", event.DeploymentID)
+			return poller.Continue(ctx, event.DeploymentID, types.DeploymentWaitTypeReadyWait)
+		}
+	case ExitPolicy:
+		return func(_ context.Context, _ deploy.DeploymentEvent) error {
+			return errDeploymentReady
+		}
+	}
+
+	return nil
+}
+
+// runVerifyCommand runs the command using the shell, with the deployment ID in the environment variable DEPLOYMENT_ID.
+func runVerifyCommand(ctx context.Context, command, deploymentID string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), "DEPLOYMENT_ID="+deploymentID)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %w", errVerificationFailed, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"testing"
+	"time"
+)
+
+// continueRecorder records ContinueDeployment calls and fails the other ones.
+type continueRecorder struct {
+	input *codedeploy.ContinueDeploymentInput
+}
+
+func (c *continueRecorder) ContinueDeployment(_ context.Context, params *codedeploy.ContinueDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.ContinueDeploymentOutput, error) {
+	c.input = params
+	return &codedeploy.ContinueDeploymentOutput{}, nil
+}
+
+func (c *continueRecorder) CreateDeployment(_ context.Context, _ *codedeploy.CreateDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.CreateDeploymentOutput, error) {
+	return nil, errors.New("unexpected CreateDeployment call")
+}
+
+func (c *continueRecorder) GetDeployment(_ context.Context, _ *codedeploy.GetDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.GetDeploymentOutput, error) {
+	return nil, errors.New("unexpected GetDeployment call")
+}
+
+func (c *continueRecorder) ListDeployments(_ context.Context, _ *codedeploy.ListDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentsOutput, error) {
+	return nil, errors.New("unexpected ListDeployments call")
+}
+
+func (c *continueRecorder) BatchGetDeployments(_ context.Context, _ *codedeploy.BatchGetDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentsOutput, error) {
+	return nil, errors.New("unexpected BatchGetDeployments call")
+}
+
+func (c *continueRecorder) ListDeploymentTargets(_ context.Context, _ *codedeploy.ListDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentTargetsOutput, error) {
+	return nil, errors.New("unexpected ListDeploymentTargets call")
+}
+
+func (c *continueRecorder) BatchGetDeploymentTargets(_ context.Context, _ *codedeploy.BatchGetDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentTargetsOutput, error) {
+	return nil, errors.New("unexpected BatchGetDeploymentTargets call")
+}
+
+func (c *continueRecorder) StopDeployment(_ context.Context, _ *codedeploy.StopDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.StopDeploymentOutput, error) {
+	return nil, errors.New("unexpected StopDeployment call")
+}
+
+func (c *continueRecorder) RegisterApplicationRevision(_ context.Context, _ *codedeploy.RegisterApplicationRevisionInput, _ ...func(*codedeploy.Options)) (*codedeploy.RegisterApplicationRevisionOutput, error) {
+	return nil, errors.New("unexpected RegisterApplicationRevision call")
+}
+
+func TestFlagContext_readyHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		arguments    []string
+		wantHandler  bool
+		wantContinue bool
+		wantErr      error
+	}{
+		{
+			name: "wait",
+		},
+		{
+			name:         "continue",
+			arguments:    []string{"-whenReady", "continue"},
+			wantHandler:  true,
+			wantContinue: true,
+		},
+		{
+			name:         "verified",
+			arguments:    []string{"-whenReady", "continue", "-verifyCommand", "test \"$DEPLOYMENT_ID\" = d-123"},
+			wantHandler:  true,
+			wantContinue: true,
+		},
+		{
+			name:        "verification failed",
+			arguments:   []string{"-whenReady", "continue", "-verifyCommand", "exit 1"},
+			wantHandler: true,
+			wantErr:     errVerificationFailed,
+		},
+		{
+			name:        "exit",
+			arguments:   []string{"-whenReady", "exit"},
+			wantHandler: true,
+			wantErr:     errDeploymentReady,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagContext := &FlagContext{FlagSet: flag.NewFlagSet("test", flag.ContinueOnError), Command: WaitCommand}
+			if err := flagContext.Parse(append([]string{"-deploymentId", "d-123"}, tt.arguments...)); err != nil {
+				t.Fatal(err)
+			}
+
+			client := &continueRecorder{}
+			handler := flagContext.readyHandler(deploy.NewDeploymentPoller(client, time.Millisecond, nil))
+			if (handler != nil) != tt.wantHandler {
+				t.Fatalf("readyHandler() = %v, wantHandler %v", handler != nil, tt.wantHandler)
+			}
+			if handler == nil {
+				return
+			}
+
+			if err := handler(context.Background(), deploy.DeploymentEvent{DeploymentID: "d-123"}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (client.input != nil) != tt.wantContinue {
+				t.Error("unexpected ContinueDeployment call")
+			}
+		})
+	}
+}
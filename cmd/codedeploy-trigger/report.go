@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"log"
+	"strings"
+	"time"
+)
+
+// deploymentLogger logs poller events, and lifecycle events and target errors only when they changed.
+type deploymentLogger struct {
+	lifecycleEventStatus map[string]types.LifecycleEventStatus
+	targetsErr           string
+}
+
+func newDeploymentLogger() *deploymentLogger {
+	return &deploymentLogger{lifecycleEventStatus: map[string]types.LifecycleEventStatus{}}
+}
+
+func (d *deploymentLogger) logEvent(event deploy.DeploymentEvent) {
+	elapsed := event.Elapsed.Round(time.Second)
+
+	if event.StatusChanged() {
+		log.Printf("deployment ID %q is %s (after %s)", event.DeploymentID, event.Status, elapsed)
+	}
+
+	overview := event.Overview
+	log.Printf("deployment ID %q targets: %d pending, %d in progress, %d ready, %d succeeded, %d failed, %d skipped (after %s)", event.DeploymentID, overview.Pending, overview.InProgress, overview.Ready, overview.Succeeded, overview.Failed, overview.Skipped, elapsed)
+
+	var targetsErr string
+	if event.TargetsErr != nil {
+		targetsErr = event.TargetsErr.Error()
+	}
+	if targetsErr != "" && targetsErr != d.targetsErr {
+		log.Printf("cannot report deployment targets: %s", targetsErr)
+	}
+	d.targetsErr = targetsErr
+
+	for _, target := range event.Targets {
+		for _, lifecycleEvent := range target.LifecycleEvents {
+			key := target.TargetID + "/" + aws.ToString(lifecycleEvent.LifecycleEventName)
+			if d.lifecycleEventStatus[key] == lifecycleEvent.Status {
+				continue
+			}
+			d.lifecycleEventStatus[key] = lifecycleEvent.Status
+
+			if lifecycleEvent.Status != types.LifecycleEventStatusPending {
+				logLifecycleEvent(target, lifecycleEvent)
+			}
+		}
+	}
+}
+
+func formatLifecycleEvent(target deploy.DeploymentTarget, event types.LifecycleEvent) string {
+	line := fmt.Sprintf("target %q lifecycle event %s is %s", target.TargetID, aws.ToString(event.LifecycleEventName), event.Status)
+
+	if event.StartTime != nil {
+		line += fmt.Sprintf(", started at %s", event.StartTime.Format(time.RFC3339))
+	}
+
+	if event.EndTime != nil {
+		line += fmt.Sprintf(", ended at %s", event.EndTime.Format(time.RFC3339))
+	}
+
+	if diagnostics := event.Diagnostics; diagnostics != nil {
+		line += fmt.Sprintf(" (error code %q, script %q): %s", diagnostics.ErrorCode, aws.ToString(diagnostics.ScriptName), aws.ToString(diagnostics.Message))
+	}
+
+	return line
+}
+
+func logLifecycleEvent(target deploy.DeploymentTarget, event types.LifecycleEvent) {
+	log.Print(formatLifecycleEvent(target, event))
+
+	if event.Diagnostics != nil && aws.ToString(event.Diagnostics.LogTail) != "" {
+		for _, line := range strings.Split(strings.TrimRight(*event.Diagnostics.LogTail, "\n"), "\n") {
+			log.Printf("  | %s", line)
+		}
+	}
+}
+
+// logDeploymentTargets logs the status and all lifecycle events of every deployment target.
+func logDeploymentTargets(ctx context.Context, client deploy.CodeDeployClient, deploymentID string) {
+	targets, err := deploy.GetDeploymentTargets(ctx, client, deploymentID)
+	if err != nil {
+		log.Printf("cannot report deployment targets: %s", err)
+		return
+	}
+
+	for _, target := range targets {
+		log.Printf("target %q (%s) is %s", target.TargetID, target.Type, target.Status)
+
+		for _, lifecycleEvent := range target.LifecycleEvents {
+			logLifecycleEvent(target, lifecycleEvent)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/joeig/codedeploy-trigger/pkg/deploy"
+	"testing"
+	"time"
+)
+
+func Test_formatLifecycleEvent(t *testing.T) {
+	target := deploy.DeploymentTarget{TargetID: "cluster:service"}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	end := start.Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		event types.LifecycleEvent
+		want  string
+	}{
+		{
+			name:  "pending",
+			event: types.LifecycleEvent{LifecycleEventName: aws.String("BeforeInstall"), Status: types.LifecycleEventStatusPending},
+			want:  `target "cluster:service" lifecycle event BeforeInstall is Pending`,
+		},
+		{
+			name: "failed",
+			event: types.LifecycleEvent{
+				LifecycleEventName: aws.String("AfterAllowTestTraffic"),
+				Status:             types.LifecycleEventStatusFailed,
+				StartTime:          &start,
+				EndTime:            &end,
+				Diagnostics: &types.Diagnostics{
+					ErrorCode:  "ScriptFailed",
+					ScriptName: aws.String("validate"),
+					Message:    aws.String("hook failed"),
+				},
+			},
+			want: `target "cluster:service" lifecycle event AfterAllowTestTraffic is Failed, started at 2024-01-02T03:04:05Z, ended at 2024-01-02T03:05:05Z (error code "ScriptFailed", script "validate"): hook failed`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLifecycleEvent(target, tt.event); got != tt.want {
+				t.Errorf("formatLifecycleEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,34 @@
 package deploy
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
 
 type Version string
 
 const DefaultVersion = "0.0"
 
+// UnmarshalJSON accepts the version both as string and as number, since AppSpec files commonly contain "version: 0.0".
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		*v = Version(number)
+		return nil
+	}
+
+	var version string
+	if err := json.Unmarshal(data, &version); err != nil {
+		return fmt.Errorf("version must be a string or number: %w", err)
+	}
+
+	*v = Version(version)
+
+	return nil
+}
+
 type TargetServiceType string
 
 const (
@@ -18,9 +41,95 @@ type LoadBalancerInfo struct {
 	ContainerPort int    `json:"ContainerPort"`
 }
 
+// UnmarshalJSON accepts the container port both as number and as string, since AppSpec files may contain "ContainerPort: "80"".
+func (l *LoadBalancerInfo) UnmarshalJSON(data []byte) error {
+	var loadBalancerInfo struct {
+		ContainerName string      `json:"ContainerName"`
+		ContainerPort json.Number `json:"ContainerPort"`
+	}
+	if err := unmarshalStrict(data, &loadBalancerInfo); err != nil {
+		return err
+	}
+
+	l.ContainerName = loadBalancerInfo.ContainerName
+	l.ContainerPort = 0
+
+	if len(loadBalancerInfo.ContainerPort) > 0 {
+		containerPort, err := strconv.Atoi(loadBalancerInfo.ContainerPort.String())
+		if err != nil {
+			return fmt.Errorf("container port must be an integer: %w", err)
+		}
+		l.ContainerPort = containerPort
+	}
+
+	return nil
+}
+
+type AssignPublicIP string
+
+const (
+	AssignPublicIPEnabled  AssignPublicIP = "ENABLED"
+	AssignPublicIPDisabled AssignPublicIP = "DISABLED"
+)
+
+type AwsvpcConfiguration struct {
+	Subnets        []string       `json:"Subnets"`
+	SecurityGroups []string       `json:"SecurityGroups,omitempty"`
+	AssignPublicIP AssignPublicIP `json:"AssignPublicIp,omitempty"`
+}
+
+type NetworkConfiguration struct {
+	AwsvpcConfiguration AwsvpcConfiguration `json:"AwsvpcConfiguration"`
+}
+
+// Limits of the weight and base of a capacity provider strategy item, as documented by ECS.
+const (
+	CapacityProviderMaxWeight = 1000
+	CapacityProviderMaxBase   = 100000
+)
+
+type CapacityProviderStrategyItem struct {
+	Base             int    `json:"Base,omitempty"`
+	CapacityProvider string `json:"CapacityProvider"`
+	Weight           int    `json:"Weight"`
+}
+
 type ECSProperties struct {
-	TaskDefinition   string           `json:"TaskDefinition"`
-	LoadBalancerInfo LoadBalancerInfo `json:"LoadBalancerInfo"`
+	TaskDefinition           string                         `json:"TaskDefinition"`
+	LoadBalancerInfo         LoadBalancerInfo               `json:"LoadBalancerInfo"`
+	PlatformVersion          string                         `json:"PlatformVersion,omitempty"`
+	NetworkConfiguration     *NetworkConfiguration          `json:"NetworkConfiguration,omitempty"`
+	CapacityProviderStrategy []CapacityProviderStrategyItem `json:"CapacityProviderStrategy,omitempty"`
+}
+
+// ECSOption sets optional properties of an ECS target service.
+type ECSOption func(*ECSProperties)
+
+// WithPlatformVersion sets the Fargate platform version of the replacement task set.
+func WithPlatformVersion(platformVersion string) ECSOption {
+	return func(p *ECSProperties) {
+		p.PlatformVersion = platformVersion
+	}
+}
+
+// WithAwsvpcConfiguration sets the network configuration of the replacement task set.
+func WithAwsvpcConfiguration(subnets, securityGroups []string, assignPublicIP AssignPublicIP) ECSOption {
+	return func(p *ECSProperties) {
+		p.NetworkConfiguration = &NetworkConfiguration{
+			AwsvpcConfiguration: AwsvpcConfiguration{
+				Subnets:        subnets,
+				SecurityGroups: securityGroups,
+				AssignPublicIP: assignPublicIP,
+			},
+		}
+	}
+}
+
+// WithCapacityProviderStrategy sets the capacity providers used by the replacement task set.
+func WithCapacityProviderStrategy(strategy ...CapacityProviderStrategyItem) ECSOption {
+	return func(p *ECSProperties) {
+		p.CapacityProviderStrategy = strategy
+	}
 }
 
 type LambdaProperties struct {
@@ -30,13 +139,112 @@ type LambdaProperties struct {
 	TargetVersion  string `json:"TargetVersion"`
 }
 
+// UnmarshalJSON accepts the versions both as string and as number, since AppSpec files commonly contain "CurrentVersion: 1".
+func (p *LambdaProperties) UnmarshalJSON(data []byte) error {
+	var properties struct {
+		Name           string  `json:"Name"`
+		Alias          string  `json:"Alias"`
+		CurrentVersion Version `json:"CurrentVersion"`
+		TargetVersion  Version `json:"TargetVersion"`
+	}
+	if err := unmarshalStrict(data, &properties); err != nil {
+		return err
+	}
+
+	*p = LambdaProperties{
+		Name:           properties.Name,
+		Alias:          properties.Alias,
+		CurrentVersion: string(properties.CurrentVersion),
+		TargetVersion:  string(properties.TargetVersion),
+	}
+
+	return nil
+}
+
+// TargetService describes the service to deploy.
+// Properties holds ECSProperties or LambdaProperties depending on the type.
 type TargetService struct {
 	Type       TargetServiceType `json:"Type"`
 	Properties any               `json:"Properties"`
 }
 
+func (t *TargetService) UnmarshalJSON(data []byte) error {
+	var targetService struct {
+		Type       TargetServiceType `json:"Type"`
+		Properties json.RawMessage   `json:"Properties"`
+	}
+	if err := unmarshalStrict(data, &targetService); err != nil {
+		return err
+	}
+
+	if len(targetService.Properties) == 0 {
+		return fmt.Errorf("target service %q has no properties", targetService.Type)
+	}
+
+	switch targetService.Type {
+	case ECSTargetServiceType:
+		var properties ECSProperties
+		if err := unmarshalStrict(targetService.Properties, &properties); err != nil {
+			return fmt.Errorf("invalid properties of target service %q: %w", targetService.Type, err)
+		}
+		t.Properties = properties
+	case LambdaTargetServiceType:
+		var properties LambdaProperties
+		if err := unmarshalStrict(targetService.Properties, &properties); err != nil {
+			return fmt.Errorf("invalid properties of target service %q: %w", targetService.Type, err)
+		}
+		t.Properties = properties
+	default:
+		return fmt.Errorf("unknown target service type %q", targetService.Type)
+	}
+
+	t.Type = targetService.Type
+
+	return nil
+}
+
+// DefaultResourceName is the name of resources which are created without an explicit name.
+const DefaultResourceName = "TargetService"
+
+// Resource contains a single target service, which is named "TargetService" for ECS services and usually after the function for Lambda functions.
 type Resource struct {
-	TargetService TargetService `json:"TargetService"`
+	Name          string
+	TargetService TargetService
+}
+
+func (r Resource) MarshalJSON() ([]byte, error) {
+	name := r.Name
+	if len(name) == 0 {
+		name = DefaultResourceName
+	}
+
+	return json.Marshal(map[string]TargetService{name: r.TargetService})
+}
+
+func (r *Resource) UnmarshalJSON(data []byte) error {
+	var resource map[string]json.RawMessage
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+
+	if len(resource) != 1 {
+		return fmt.Errorf("resource must contain exactly one target service, got %d", len(resource))
+	}
+
+	for name, targetService := range resource {
+		r.Name = name
+		if err := unmarshalStrict(targetService, &r.TargetService); err != nil {
+			return fmt.Errorf("resource %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
 }
 
 // AppSpec provides an application specification for CodeDeploy.
@@ -44,23 +252,31 @@ type Resource struct {
 type AppSpec struct {
 	Version   Version    `json:"version"`
 	Resources []Resource `json:"Resources"`
+	Hooks     []Hook     `json:"Hooks,omitempty"`
 }
 
 // NewECS creates a new instance of AppSpec incorporating commonly used default values for ECS service deployments.
-func NewECS(taskDefinitionARN, containerName string, containerPort int) *AppSpec {
+// Optional properties which are not set by any of the options are omitted.
+func NewECS(taskDefinitionARN, containerName string, containerPort int, options ...ECSOption) *AppSpec {
+	properties := ECSProperties{
+		TaskDefinition: taskDefinitionARN,
+		LoadBalancerInfo: LoadBalancerInfo{
+			ContainerName: containerName,
+			ContainerPort: containerPort,
+		},
+	}
+
+	for _, option := range options {
+		option(&properties)
+	}
+
 	return &AppSpec{
 		Version: DefaultVersion,
 		Resources: []Resource{
 			{
 				TargetService: TargetService{
-					Type: ECSTargetServiceType,
-					Properties: ECSProperties{
-						TaskDefinition: taskDefinitionARN,
-						LoadBalancerInfo: LoadBalancerInfo{
-							ContainerName: containerName,
-							ContainerPort: containerPort,
-						},
-					},
+					Type:       ECSTargetServiceType,
+					Properties: properties,
 				},
 			},
 		},
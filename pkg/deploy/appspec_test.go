@@ -1,6 +1,8 @@
 package deploy
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -18,6 +20,30 @@ func TestAppSpec_NewECS(t *testing.T) {
 	}
 }
 
+func TestAppSpec_NewECS_options(t *testing.T) {
+	appSpec := NewECS(
+		"this:is:the:arn",
+		"containerName",
+		1337,
+		WithPlatformVersion("1.4.0"),
+		WithAwsvpcConfiguration([]string{"subnet-1", "subnet-2"}, []string{"sg-1"}, AssignPublicIPDisabled),
+		WithCapacityProviderStrategy(
+			CapacityProviderStrategyItem{CapacityProvider: "FARGATE", Base: 1, Weight: 1},
+			CapacityProviderStrategyItem{CapacityProvider: "FARGATE_SPOT", Weight: 3},
+		),
+	)
+
+	result, err := appSpec.MarshalJSON()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := `{"version":"0.0","Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"this:is:the:arn","LoadBalancerInfo":{"ContainerName":"containerName","ContainerPort":1337},"PlatformVersion":"1.4.0","NetworkConfiguration":{"AwsvpcConfiguration":{"Subnets":["subnet-1","subnet-2"],"SecurityGroups":["sg-1"],"AssignPublicIp":"DISABLED"}},"CapacityProviderStrategy":[{"Base":1,"CapacityProvider":"FARGATE","Weight":1},{"CapacityProvider":"FARGATE_SPOT","Weight":3}]}}}]}`
+	if string(result) != want {
+		t.Error("resulting JSON is wrong")
+	}
+}
+
 func TestAppSpec_NewLambda(t *testing.T) {
 	appSpec := NewLambda("function-name", "function-alias", "42", "43")
 
@@ -31,3 +57,45 @@ func TestAppSpec_NewLambda(t *testing.T) {
 		t.Error("resulting JSON is wrong")
 	}
 }
+
+func TestTargetService_UnmarshalJSON(t *testing.T) {
+	for _, appSpec := range []*AppSpec{
+		NewECS("this:is:the:arn", "containerName", 1337, WithPlatformVersion("LATEST")),
+		NewLambda("function-name", "function-alias", "42", "43"),
+	} {
+		data, err := appSpec.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result AppSpec
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+
+		appSpec.Resources[0].Name = DefaultResourceName
+		if !reflect.DeepEqual(appSpec, &result) {
+			t.Errorf("unexpected AppSpec after round trip: %+v", result)
+		}
+	}
+}
+
+func TestTargetService_UnmarshalJSON_error(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "unknown type", data: `{"Type":"AWS::EC2::Instance","Properties":{}}`},
+		{name: "missing properties", data: `{"Type":"AWS::ECS::Service"}`},
+		{name: "unknown property", data: `{"Type":"AWS::Lambda::Function","Properties":{"TaskDefinition":"arn"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targetService TargetService
+			if err := json.Unmarshal([]byte(tt.data), &targetService); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ServerAppSpecFileName is the name of the AppSpec file which EC2/on-premises revisions must contain at their root.
+const ServerAppSpecFileName = "appspec.yml"
+
+// BundleDirectory writes the directory as revision bundle of the given type, which is either zip or tgz.
+// The directory must contain an appspec.yml file.
+func BundleDirectory(w io.Writer, directory string, bundleType types.BundleType) error {
+	if _, err := os.Stat(filepath.Join(directory, ServerAppSpecFileName)); err != nil {
+		return fmt.Errorf("cannot find %s in directory %q: %w", ServerAppSpecFileName, directory, err)
+	}
+
+	switch bundleType {
+	case types.BundleTypeZip:
+		return bundleZip(w, os.DirFS(directory))
+	case types.BundleTypeTarGZip:
+		return bundleTarGZip(w, os.DirFS(directory))
+	}
+
+	return fmt.Errorf("bundle type %q is not supported", bundleType)
+}
+
+// walkBundle calls add for each file and directory below the root, and refuses symbolic links and other special files.
+func walkBundle(fsys fs.FS, add func(name string, info fs.FileInfo) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name == "." {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return fmt.Errorf("cannot bundle %q, because it is neither a regular file nor a directory", name)
+		}
+
+		return add(name, info)
+	})
+}
+
+func bundleZip(w io.Writer, fsys fs.FS) error {
+	zipWriter := zip.NewWriter(w)
+
+	err := walkBundle(fsys, func(name string, info fs.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		fileWriter, err := zipWriter.CreateHeader(header)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		return copyBundleFile(fileWriter, fsys, name)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot bundle directory: %w", err)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("cannot bundle directory: %w", err)
+	}
+
+	return nil
+}
+
+func bundleTarGZip(w io.Writer, fsys fs.FS) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := walkBundle(fsys, func(name string, info fs.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil || info.IsDir() {
+			return err
+		}
+
+		return copyBundleFile(tarWriter, fsys, name)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot bundle directory: %w", err)
+	}
+
+	if err := errors.Join(tarWriter.Close(), gzipWriter.Close()); err != nil {
+		return fmt.Errorf("cannot bundle directory: %w", err)
+	}
+
+	return nil
+}
+
+func copyBundleFile(w io.Writer, fsys fs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newBundleDirectory(t *testing.T) string {
+	directory := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(directory, ServerAppSpecFileName), []byte("version: 0.0\nos: linux\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(directory, "scripts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(directory, "scripts", "start.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return directory
+}
+
+func TestBundleDirectory_zip(t *testing.T) {
+	var bundle bytes.Buffer
+	if err := BundleDirectory(&bundle, newBundleDirectory(t), types.BundleTypeZip); err != nil {
+		t.Fatal(err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, file := range zipReader.File {
+		names = append(names, file.Name)
+
+		if file.Name == "scripts/start.sh" && file.Mode().Perm() != 0o755 {
+			t.Errorf("unexpected mode: %s", file.Mode())
+		}
+	}
+
+	if !slices.Equal(names, []string{ServerAppSpecFileName, "scripts/", "scripts/start.sh"}) {
+		t.Errorf("unexpected files: %v", names)
+	}
+}
+
+func TestBundleDirectory_tgz(t *testing.T) {
+	var bundle bytes.Buffer
+	if err := BundleDirectory(&bundle, newBundleDirectory(t), types.BundleTypeTarGZip); err != nil {
+		t.Fatal(err)
+	}
+
+	gzipReader, err := gzip.NewReader(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	contents := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := io.ReadAll(tarReader)
+		contents[header.Name] = string(content)
+	}
+
+	if len(contents) != 3 || contents[ServerAppSpecFileName] != "version: 0.0\nos: linux\n" || contents["scripts/start.sh"] != "#!/bin/sh\n" {
+		t.Errorf("unexpected files: %v", contents)
+	}
+}
+
+func TestBundleDirectory_error(t *testing.T) {
+	tests := []struct {
+		name       string
+		directory  func(t *testing.T) string
+		bundleType types.BundleType
+		wantErr    error
+	}{
+		{"missing AppSpec", func(t *testing.T) string { return t.TempDir() }, types.BundleTypeZip, fs.ErrNotExist},
+		{"unsupported bundle type", newBundleDirectory, types.BundleTypeTar, nil},
+		{"symbolic link", func(t *testing.T) string {
+			directory := newBundleDirectory(t)
+			if err := os.Symlink(ServerAppSpecFileName, filepath.Join(directory, "link.yml")); err != nil {
+				t.Skip(err)
+			}
+			return directory
+		}, types.BundleTypeZip, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := BundleDirectory(io.Discard, tt.directory(t), tt.bundleType)
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("BundleDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -12,25 +12,98 @@ import (
 	"time"
 )
 
-func assembleCreateDeploymentInput(applicationName, deploymentGroupName string, appSpecJson []byte) *codedeploy.CreateDeploymentInput {
-	appSpecJsonHash := sha256.Sum256(appSpecJson)
+func appSpecSha256(appSpecJson []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(appSpecJson))
+}
 
+func assembleCreateDeploymentInput(applicationName, deploymentGroupName string, revision RevisionSource) *codedeploy.CreateDeploymentInput {
 	return &codedeploy.CreateDeploymentInput{
 		ApplicationName:     aws.String(applicationName),
 		DeploymentGroupName: aws.String(deploymentGroupName),
-		Revision: &types.RevisionLocation{
-			AppSpecContent: &types.AppSpecContent{
-				Content: aws.String(string(appSpecJson)),
-				Sha256:  aws.String(fmt.Sprintf("%x", appSpecJsonHash)),
-			},
-			RevisionType: types.RevisionLocationTypeAppSpecContent,
-		},
+		Revision:            revision.RevisionLocation(),
+	}
+}
+
+// DeploymentOption overrides settings of the deployment group for a single deployment.
+type DeploymentOption func(*codedeploy.CreateDeploymentInput)
+
+// WithDeploymentConfigName sets the deployment configuration, e.g. "CodeDeployDefault.ECSAllAtOnce".
+func WithDeploymentConfigName(deploymentConfigName string) DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.DeploymentConfigName = aws.String(deploymentConfigName)
+	}
+}
+
+// WithAutoRollback enables automatic rollbacks on the given events.
+func WithAutoRollback(events ...types.AutoRollbackEvent) DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.AutoRollbackConfiguration = &types.AutoRollbackConfiguration{Enabled: true, Events: events}
+	}
+}
+
+// WithoutAutoRollback disables automatic rollbacks.
+func WithoutAutoRollback() DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.AutoRollbackConfiguration = &types.AutoRollbackConfiguration{Enabled: false}
+	}
+}
+
+// WithAlarms monitors only the given CloudWatch alarms. They replace all alarms of the deployment group rather than adding to or excluding from them.
+func WithAlarms(alarmNames ...string) DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		alarms := make([]types.Alarm, 0, len(alarmNames))
+		for _, alarmName := range alarmNames {
+			alarms = append(alarms, types.Alarm{Name: aws.String(alarmName)})
+		}
+		input.OverrideAlarmConfiguration = &types.AlarmConfiguration{Enabled: true, Alarms: alarms}
+	}
+}
+
+// WithoutAlarms disables monitoring the CloudWatch alarms of the deployment group.
+func WithoutAlarms() DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.OverrideAlarmConfiguration = &types.AlarmConfiguration{Enabled: false}
+	}
+}
+
+// WithDescription sets the description of the deployment.
+func WithDescription(description string) DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.Description = aws.String(description)
+	}
+}
+
+// WithFileExistsBehavior sets how EC2/on-premises deployments handle files which already exist on the instances.
+func WithFileExistsBehavior(fileExistsBehavior types.FileExistsBehavior) DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.FileExistsBehavior = fileExistsBehavior
+	}
+}
+
+// WithIgnoreApplicationStopFailures lets EC2/on-premises deployments continue if the ApplicationStop lifecycle event fails.
+func WithIgnoreApplicationStopFailures() DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.IgnoreApplicationStopFailures = true
+	}
+}
+
+// WithUpdateOutdatedInstancesOnly limits EC2/on-premises deployments to instances which don't run the latest revision of the application.
+func WithUpdateOutdatedInstancesOnly() DeploymentOption {
+	return func(input *codedeploy.CreateDeploymentInput) {
+		input.UpdateOutdatedInstancesOnly = true
 	}
 }
 
 type CodeDeployClient interface {
 	CreateDeployment(ctx context.Context, params *codedeploy.CreateDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.CreateDeploymentOutput, error)
 	GetDeployment(ctx context.Context, params *codedeploy.GetDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.GetDeploymentOutput, error)
+	ListDeployments(ctx context.Context, params *codedeploy.ListDeploymentsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentsOutput, error)
+	BatchGetDeployments(ctx context.Context, params *codedeploy.BatchGetDeploymentsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentsOutput, error)
+	ListDeploymentTargets(ctx context.Context, params *codedeploy.ListDeploymentTargetsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentTargetsOutput, error)
+	BatchGetDeploymentTargets(ctx context.Context, params *codedeploy.BatchGetDeploymentTargetsInput, optFns ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentTargetsOutput, error)
+	StopDeployment(ctx context.Context, params *codedeploy.StopDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.StopDeploymentOutput, error)
+	ContinueDeployment(ctx context.Context, params *codedeploy.ContinueDeploymentInput, optFns ...func(*codedeploy.Options)) (*codedeploy.ContinueDeploymentOutput, error)
+	RegisterApplicationRevision(ctx context.Context, params *codedeploy.RegisterApplicationRevisionInput, optFns ...func(*codedeploy.Options)) (*codedeploy.RegisterApplicationRevisionOutput, error)
 }
 
 type DeploymentSuccessfulWaiter func(ctx context.Context, params *codedeploy.GetDeploymentInput, maxWaitDur time.Duration, optFns ...func(*codedeploy.DeploymentSuccessfulWaiterOptions)) error
@@ -42,7 +115,9 @@ type CodeDeployContext struct {
 	DeploymentSuccessfulWaiter DeploymentSuccessfulWaiter
 	FileReader                 FileReader
 
+	appSpec     *AppSpec
 	appSpecJson []byte
+	revision    RevisionSource
 }
 
 func NewCodeDeployContext(client CodeDeployClient, deploymentSuccessfulWaiter DeploymentSuccessfulWaiter, fileReader FileReader) *CodeDeployContext {
@@ -56,27 +131,71 @@ func (c *CodeDeployContext) WithAppSpec(appSpec json.Marshaler) (*CodeDeployCont
 	}
 
 	c.appSpecJson = appSpecJson
+	c.appSpec, _ = appSpec.(*AppSpec)
+	c.revision = AppSpecContentRevision{Content: appSpecJson}
 
 	return c, nil
 }
 
+// WithRevision deploys a revision from another source, e.g. S3Revision, instead of an AppSpec.
+func (c *CodeDeployContext) WithRevision(revision RevisionSource) *CodeDeployContext {
+	c.appSpecJson = nil
+	c.appSpec = nil
+	c.revision = revision
+
+	return c
+}
+
+// AppSpec returns the AppSpec which has been set using WithAppSpec or WithAppSpecFile.
+// It returns nil if no AppSpec has been set or if WithAppSpec has been called with a custom json.Marshaler.
+func (c *CodeDeployContext) AppSpec() *AppSpec {
+	return c.appSpec
+}
+
+// AppSpecSha256 returns the hash which CreateDeployment sends along with the AppSpec, or an empty string if no AppSpec has been set.
+func (c *CodeDeployContext) AppSpecSha256() string {
+	if c.appSpecJson == nil {
+		return ""
+	}
+	return appSpecSha256(c.appSpecJson)
+}
+
+// WithAppSpecFile reads an AppSpec file in either JSON or YAML format and normalizes it to JSON.
 func (c *CodeDeployContext) WithAppSpecFile(fileName string) (*CodeDeployContext, error) {
-	appSpecJson, err := c.FileReader(fileName)
+	data, err := c.FileReader(fileName)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read file: %w", err)
 	}
 
-	c.appSpecJson = appSpecJson
+	appSpec, err := ParseAppSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse file %q: %w", fileName, err)
+	}
 
-	return c, nil
+	return c.WithAppSpec(appSpec)
 }
 
-func (c *CodeDeployContext) CreateDeployment(ctx context.Context, applicationName, deploymentGroupName string) (string, error) {
-	if c.appSpecJson == nil {
-		return "", errors.New("cannot create deployment: app spec is empty")
+// CreateDeploymentInput returns the request which CreateDeployment sends to CodeDeploy, without sending it.
+func (c *CodeDeployContext) CreateDeploymentInput(applicationName, deploymentGroupName string, options ...DeploymentOption) (*codedeploy.CreateDeploymentInput, error) {
+	if c.revision == nil {
+		return nil, errors.New("revision is empty")
 	}
 
-	deployment, err := c.Client.CreateDeployment(ctx, assembleCreateDeploymentInput(applicationName, deploymentGroupName, c.appSpecJson))
+	input := assembleCreateDeploymentInput(applicationName, deploymentGroupName, c.revision)
+	for _, option := range options {
+		option(input)
+	}
+
+	return input, nil
+}
+
+func (c *CodeDeployContext) CreateDeployment(ctx context.Context, applicationName, deploymentGroupName string, options ...DeploymentOption) (string, error) {
+	input, err := c.CreateDeploymentInput(applicationName, deploymentGroupName, options...)
+	if err != nil {
+		return "", fmt.Errorf("cannot create deployment: %w", err)
+	}
+
+	deployment, err := c.Client.CreateDeployment(ctx, input)
 	if err != nil {
 		return "", fmt.Errorf("cannot create deployment: %w", err)
 	}
@@ -84,12 +203,15 @@ func (c *CodeDeployContext) CreateDeployment(ctx context.Context, applicationNam
 	return *deployment.DeploymentId, nil
 }
 
+// WaitForSuccessfulDeployment returns a *DeploymentFailedError if CodeDeploy reports the deployment as failed or stopped.
 func (c *CodeDeployContext) WaitForSuccessfulDeployment(ctx context.Context, deploymentID string, maxWaitDur time.Duration) error {
 	deployment := &codedeploy.GetDeploymentInput{DeploymentId: aws.String(deploymentID)}
 
 	if err := c.DeploymentSuccessfulWaiter(ctx, deployment, maxWaitDur); err != nil {
-		if output, getDeploymentErr := c.Client.GetDeployment(ctx, deployment); output != nil && output.DeploymentInfo != nil && output.DeploymentInfo.ErrorInformation != nil && output.DeploymentInfo.ErrorInformation.Message != nil && getDeploymentErr != nil {
-			return fmt.Errorf("%s (%w)", *output.DeploymentInfo.ErrorInformation.Message, err)
+		if output, getDeploymentErr := c.Client.GetDeployment(ctx, deployment); getDeploymentErr == nil && output != nil && output.DeploymentInfo != nil {
+			if failedErr := newDeploymentFailedError(ctx, c.Client, deploymentID, output.DeploymentInfo, err); failedErr != nil {
+				return failedErr
+			}
 		}
 
 		return err
@@ -97,3 +219,22 @@ func (c *CodeDeployContext) WaitForSuccessfulDeployment(ctx context.Context, dep
 
 	return nil
 }
+
+// WaitForRollback waits for the rollback deployment which CodeDeploy created for the failed deployment.
+// It returns ErrNoRollbackDeployment if there is none, and an error wrapping ErrRollbackFailed and the rollback's *DeploymentFailedError if the rollback didn't succeed.
+// The DeploymentSuccessfulWaiter should wait until the rollback finished, e.g. the Wait method of DeploymentPoller.WithoutHandlers.
+func (c *CodeDeployContext) WaitForRollback(ctx context.Context, failedErr *DeploymentFailedError, maxWaitDur time.Duration) error {
+	rollbackDeploymentID := failedErr.RollbackDeploymentID()
+	if rollbackDeploymentID == "" {
+		return ErrNoRollbackDeployment
+	}
+
+	err := c.WaitForSuccessfulDeployment(ctx, rollbackDeploymentID, maxWaitDur)
+
+	var rollbackFailedErr *DeploymentFailedError
+	if errors.As(err, &rollbackFailedErr) {
+		return fmt.Errorf("%w: %w", ErrRollbackFailed, err)
+	}
+
+	return err
+}
@@ -8,12 +8,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
 	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"strconv"
 	"testing"
 	"time"
 )
 
 func Test_assembleCreateDeploymentInput(t *testing.T) {
-	input := assembleCreateDeploymentInput("app", "group", []byte("{}"))
+	input := assembleCreateDeploymentInput("app", "group", AppSpecContentRevision{Content: []byte("{}")})
 	want := codedeploy.CreateDeploymentInput{
 		ApplicationName:     aws.String("app"),
 		DeploymentGroupName: aws.String("group"),
@@ -38,17 +39,122 @@ type mockCodeDeployClient struct {
 	CreateDeploymentOutput *codedeploy.CreateDeploymentOutput
 	CreateDeploymentErr    error
 	GetDeploymentOutput    *codedeploy.GetDeploymentOutput
+	GetDeploymentOutputs   []*codedeploy.GetDeploymentOutput
 	GetDeploymentErr       error
+
+	ListDeploymentsInputs  []*codedeploy.ListDeploymentsInput
+	ListDeploymentsOutputs []*codedeploy.ListDeploymentsOutput
+	ListDeploymentsErr     error
+
+	BatchGetDeploymentsInputs []*codedeploy.BatchGetDeploymentsInput
+	BatchGetDeploymentsOutput *codedeploy.BatchGetDeploymentsOutput
+	BatchGetDeploymentsErr    error
+
+	ListDeploymentTargetsOutputs    []*codedeploy.ListDeploymentTargetsOutput
+	ListDeploymentTargetsErr        error
+	BatchGetDeploymentTargetsInputs []*codedeploy.BatchGetDeploymentTargetsInput
+	BatchGetDeploymentTargetsOutput *codedeploy.BatchGetDeploymentTargetsOutput
+	BatchGetDeploymentTargetsErr    error
+
+	StopDeploymentInput *codedeploy.StopDeploymentInput
+	StopDeploymentErr   error
+
+	ContinueDeploymentInputs []*codedeploy.ContinueDeploymentInput
+	ContinueDeploymentErr    error
+
+	RegisterApplicationRevisionInput *codedeploy.RegisterApplicationRevisionInput
+	RegisterApplicationRevisionErr   error
 }
 
 func (m *mockCodeDeployClient) CreateDeployment(_ context.Context, _ *codedeploy.CreateDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.CreateDeploymentOutput, error) {
 	return m.CreateDeploymentOutput, m.CreateDeploymentErr
 }
 
+// GetDeployment returns GetDeploymentOutputs one after another, and GetDeploymentOutput afterwards.
 func (m *mockCodeDeployClient) GetDeployment(_ context.Context, _ *codedeploy.GetDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.GetDeploymentOutput, error) {
+	if len(m.GetDeploymentOutputs) > 0 {
+		output := m.GetDeploymentOutputs[0]
+		m.GetDeploymentOutputs = m.GetDeploymentOutputs[1:]
+		return output, m.GetDeploymentErr
+	}
 	return m.GetDeploymentOutput, m.GetDeploymentErr
 }
 
+// ListDeployments returns ListDeploymentsOutputs page by page, using the page index as NextToken.
+func (m *mockCodeDeployClient) ListDeployments(_ context.Context, params *codedeploy.ListDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentsOutput, error) {
+	m.ListDeploymentsInputs = append(m.ListDeploymentsInputs, params)
+	if m.ListDeploymentsErr != nil {
+		return nil, m.ListDeploymentsErr
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page, _ = strconv.Atoi(*params.NextToken)
+	}
+
+	if page >= len(m.ListDeploymentsOutputs) {
+		return &codedeploy.ListDeploymentsOutput{}, nil
+	}
+	return m.ListDeploymentsOutputs[page], nil
+}
+
+func (m *mockCodeDeployClient) BatchGetDeployments(_ context.Context, params *codedeploy.BatchGetDeploymentsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentsOutput, error) {
+	m.BatchGetDeploymentsInputs = append(m.BatchGetDeploymentsInputs, params)
+	if m.BatchGetDeploymentsOutput == nil {
+		return &codedeploy.BatchGetDeploymentsOutput{}, m.BatchGetDeploymentsErr
+	}
+	return m.BatchGetDeploymentsOutput, m.BatchGetDeploymentsErr
+}
+
+// ListDeploymentTargets returns ListDeploymentTargetsOutputs page by page, using the page index as NextToken.
+func (m *mockCodeDeployClient) ListDeploymentTargets(_ context.Context, params *codedeploy.ListDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.ListDeploymentTargetsOutput, error) {
+	if m.ListDeploymentTargetsErr != nil {
+		return nil, m.ListDeploymentTargetsErr
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page, _ = strconv.Atoi(*params.NextToken)
+	}
+
+	if page >= len(m.ListDeploymentTargetsOutputs) {
+		return &codedeploy.ListDeploymentTargetsOutput{}, nil
+	}
+	return m.ListDeploymentTargetsOutputs[page], nil
+}
+
+func (m *mockCodeDeployClient) StopDeployment(_ context.Context, params *codedeploy.StopDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.StopDeploymentOutput, error) {
+	m.StopDeploymentInput = params
+	if m.StopDeploymentErr != nil {
+		return nil, m.StopDeploymentErr
+	}
+	return &codedeploy.StopDeploymentOutput{Status: types.StopStatusPending}, nil
+}
+
+func (m *mockCodeDeployClient) ContinueDeployment(_ context.Context, params *codedeploy.ContinueDeploymentInput, _ ...func(*codedeploy.Options)) (*codedeploy.ContinueDeploymentOutput, error) {
+	m.ContinueDeploymentInputs = append(m.ContinueDeploymentInputs, params)
+	if m.ContinueDeploymentErr != nil {
+		return nil, m.ContinueDeploymentErr
+	}
+	return &codedeploy.ContinueDeploymentOutput{}, nil
+}
+
+func (m *mockCodeDeployClient) RegisterApplicationRevision(_ context.Context, params *codedeploy.RegisterApplicationRevisionInput, _ ...func(*codedeploy.Options)) (*codedeploy.RegisterApplicationRevisionOutput, error) {
+	m.RegisterApplicationRevisionInput = params
+	if m.RegisterApplicationRevisionErr != nil {
+		return nil, m.RegisterApplicationRevisionErr
+	}
+	return &codedeploy.RegisterApplicationRevisionOutput{}, nil
+}
+
+func (m *mockCodeDeployClient) BatchGetDeploymentTargets(_ context.Context, params *codedeploy.BatchGetDeploymentTargetsInput, _ ...func(*codedeploy.Options)) (*codedeploy.BatchGetDeploymentTargetsOutput, error) {
+	m.BatchGetDeploymentTargetsInputs = append(m.BatchGetDeploymentTargetsInputs, params)
+	if m.BatchGetDeploymentTargetsOutput == nil {
+		return &codedeploy.BatchGetDeploymentTargetsOutput{}, m.BatchGetDeploymentTargetsErr
+	}
+	return m.BatchGetDeploymentTargetsOutput, m.BatchGetDeploymentTargetsErr
+}
+
 func TestCodeDeployContext_CreateDeployment(t *testing.T) {
 	deploymentID := "mock"
 	codeDeployContext, _ := NewCodeDeployContext(&mockCodeDeployClient{CreateDeploymentOutput: &codedeploy.CreateDeploymentOutput{DeploymentId: aws.String(deploymentID)}}, nil, nil).WithAppSpec(&AppSpec{})
@@ -76,6 +182,38 @@ func TestCodeDeployContext_CreateDeployment_error(t *testing.T) {
 	}
 }
 
+func TestCodeDeployContext_CreateDeploymentInput(t *testing.T) {
+	codeDeployContext, _ := NewCodeDeployContext(nil, nil, nil).WithAppSpec(&AppSpec{})
+	input, err := codeDeployContext.CreateDeploymentInput("a", "d")
+	if err != nil {
+		t.Fatal("unexpected error")
+	}
+
+	if *input.ApplicationName != "a" || *input.DeploymentGroupName != "d" || *input.Revision.AppSpecContent.Content != `{"version":"","Resources":null}` {
+		t.Error("unexpected input")
+	}
+}
+
+func TestCodeDeployContext_AppSpecSha256(t *testing.T) {
+	codeDeployContext := NewCodeDeployContext(nil, nil, nil)
+	if codeDeployContext.AppSpecSha256() != "" {
+		t.Error("unexpected hash without AppSpec")
+	}
+
+	codeDeployContext, _ = codeDeployContext.WithAppSpec(&AppSpec{})
+	input, _ := codeDeployContext.CreateDeploymentInput("a", "d")
+
+	if codeDeployContext.AppSpecSha256() != *input.Revision.AppSpecContent.Sha256 {
+		t.Error("unexpected hash")
+	}
+}
+
+func TestCodeDeployContext_CreateDeploymentInput_error(t *testing.T) {
+	if _, err := NewCodeDeployContext(nil, nil, nil).CreateDeploymentInput("a", "d"); err == nil {
+		t.Error("no error")
+	}
+}
+
 func NewMockDeploymentSuccessfulWaiter(waitErr error) DeploymentSuccessfulWaiter {
 	return func(ctx context.Context, params *codedeploy.GetDeploymentInput, maxWaitDur time.Duration, optFns ...func(*codedeploy.DeploymentSuccessfulWaiterOptions)) error {
 		return waitErr
@@ -113,8 +251,71 @@ func TestCodeDeployContext_WaitForSuccessfulDeployment_error_details(t *testing.
 	}
 
 	err := codeDeployContext.WaitForSuccessfulDeployment(context.Background(), "mock", 1)
-	if err.Error() != "mock" {
-		t.Error("unexpected err")
+
+	var failedErr *DeploymentFailedError
+	if !errors.As(err, &failedErr) || failedErr.Message != "info" || failedErr.DeploymentID != "mock" {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+	if err.Error() != `deployment "mock" failed: info (mock)` {
+		t.Errorf("unexpected err message: %s", err)
+	}
+}
+
+func TestCodeDeployContext_WaitForSuccessfulDeployment_error_lifecycleEvent(t *testing.T) {
+	waitErr := errors.New("mock")
+	codeDeployContext := CodeDeployContext{
+		Client: &mockCodeDeployClient{
+			GetDeploymentOutput: &codedeploy.GetDeploymentOutput{
+				DeploymentInfo: &types.DeploymentInfo{
+					Status: types.DeploymentStatusFailed,
+					ErrorInformation: &types.ErrorInformation{
+						Code:    "ECS_UPDATE_ERROR",
+						Message: aws.String("info"),
+					},
+					RollbackInfo: &types.RollbackInfo{RollbackDeploymentId: aws.String("rollback")},
+				},
+			},
+			ListDeploymentTargetsOutputs: []*codedeploy.ListDeploymentTargetsOutput{{TargetIds: []string{"target"}}},
+			BatchGetDeploymentTargetsOutput: &codedeploy.BatchGetDeploymentTargetsOutput{
+				DeploymentTargets: []types.DeploymentTarget{{
+					EcsTarget: &types.ECSTarget{
+						TargetId:        aws.String("target"),
+						LifecycleEvents: []types.LifecycleEvent{{LifecycleEventName: aws.String("AfterAllowTestTraffic"), Status: types.LifecycleEventStatusFailed}},
+					},
+				}},
+			},
+		},
+		DeploymentSuccessfulWaiter: NewMockDeploymentSuccessfulWaiter(waitErr),
+	}
+
+	err := codeDeployContext.WaitForSuccessfulDeployment(context.Background(), "mock", 1)
+
+	var failedErr *DeploymentFailedError
+	if !errors.As(err, &failedErr) || !errors.Is(err, waitErr) {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if failedErr.Status != types.DeploymentStatusFailed || failedErr.Code != "ECS_UPDATE_ERROR" || failedErr.TargetID != "target" || *failedErr.LifecycleEvent.LifecycleEventName != "AfterAllowTestTraffic" || *failedErr.RollbackInfo.RollbackDeploymentId != "rollback" {
+		t.Errorf("unexpected details: %+v", failedErr)
+	}
+
+	if err.Error() != `deployment "mock" is Failed: info [ECS_UPDATE_ERROR]; lifecycle event AfterAllowTestTraffic of target "target" failed; rolled back by deployment "rollback" (mock)` {
+		t.Errorf("unexpected err message: %s", err)
+	}
+}
+
+func TestCodeDeployContext_WaitForSuccessfulDeployment_error_inProgress(t *testing.T) {
+	waitErr := errors.New("mock")
+	codeDeployContext := CodeDeployContext{
+		Client: &mockCodeDeployClient{
+			GetDeploymentOutput: &codedeploy.GetDeploymentOutput{DeploymentInfo: &types.DeploymentInfo{Status: types.DeploymentStatusInProgress}},
+		},
+		DeploymentSuccessfulWaiter: NewMockDeploymentSuccessfulWaiter(waitErr),
+	}
+
+	if err := codeDeployContext.WaitForSuccessfulDeployment(context.Background(), "mock", 1); err != waitErr {
+		t.Errorf("unexpected err: %v", err)
 	}
 }
 
@@ -138,16 +339,28 @@ func NewMockFileReader(want []byte, wantErr error) FileReader {
 }
 
 func TestCodeDeployContext_WithAppSpecFile(t *testing.T) {
-	want := []byte("foo")
+	want := []byte("version: 0.0\nResources:\n  - TargetService:\n      Type: AWS::ECS::Service\n      Properties:\n        TaskDefinition: arn\n        LoadBalancerInfo:\n          ContainerName: app\n          ContainerPort: 1337\n")
 	codeDeployContext := CodeDeployContext{FileReader: NewMockFileReader(want, nil)}
 
 	if _, err := codeDeployContext.WithAppSpecFile("foo"); err != nil {
 		t.Error("unexpected err")
 	}
 
-	if !bytes.Equal(codeDeployContext.appSpecJson, []byte("foo")) {
+	if !bytes.Equal(codeDeployContext.appSpecJson, []byte(`{"version":"0.0","Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"arn","LoadBalancerInfo":{"ContainerName":"app","ContainerPort":1337}}}}]}`)) {
 		t.Error("invalid app spec JSON")
 	}
+
+	if properties, ok := codeDeployContext.AppSpec().Resources[0].TargetService.Properties.(ECSProperties); !ok || properties.TaskDefinition != "arn" {
+		t.Error("invalid app spec")
+	}
+}
+
+func TestCodeDeployContext_WithAppSpecFile_ParseError(t *testing.T) {
+	codeDeployContext := CodeDeployContext{FileReader: NewMockFileReader([]byte("foo"), nil)}
+
+	if _, err := codeDeployContext.WithAppSpecFile("foo"); err == nil {
+		t.Error("no err")
+	}
 }
 
 func TestCodeDeployContext_WithAppSpecFile_Error(t *testing.T) {
@@ -157,3 +370,96 @@ func TestCodeDeployContext_WithAppSpecFile_Error(t *testing.T) {
 		t.Error("no err")
 	}
 }
+
+func TestCodeDeployContext_WaitForRollback(t *testing.T) {
+	failedErr := &DeploymentFailedError{DeploymentID: "mock", RollbackInfo: &types.RollbackInfo{RollbackDeploymentId: aws.String("rollback")}}
+	waitErr := errors.New("mock")
+
+	tests := []struct {
+		name      string
+		failedErr *DeploymentFailedError
+		waitErr   error
+		status    types.DeploymentStatus
+		wantErr   error
+	}{
+		{
+			name:      "rolled back",
+			failedErr: failedErr,
+		},
+		{
+			name:      "rollback failed",
+			failedErr: failedErr,
+			waitErr:   waitErr,
+			status:    types.DeploymentStatusFailed,
+			wantErr:   ErrRollbackFailed,
+		},
+		{
+			name:      "rollback in progress",
+			failedErr: failedErr,
+			waitErr:   waitErr,
+			status:    types.DeploymentStatusInProgress,
+			wantErr:   waitErr,
+		},
+		{
+			name:      "no rollback",
+			failedErr: &DeploymentFailedError{DeploymentID: "mock"},
+			wantErr:   ErrNoRollbackDeployment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codeDeployContext := CodeDeployContext{
+				Client:                     &mockCodeDeployClient{GetDeploymentOutput: &codedeploy.GetDeploymentOutput{DeploymentInfo: &types.DeploymentInfo{Status: tt.status}}},
+				DeploymentSuccessfulWaiter: NewMockDeploymentSuccessfulWaiter(tt.waitErr),
+			}
+
+			err := codeDeployContext.WaitForRollback(context.Background(), tt.failedErr, 1)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("WaitForRollback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var rollbackFailedErr *DeploymentFailedError
+			if tt.wantErr == ErrRollbackFailed && (!errors.As(err, &rollbackFailedErr) || rollbackFailedErr.DeploymentID != "rollback") {
+				t.Errorf("unexpected err: %v", err)
+			}
+		})
+	}
+}
+
+func TestCodeDeployContext_CreateDeploymentInput_options(t *testing.T) {
+	codeDeployContext, _ := NewCodeDeployContext(nil, nil, nil).WithAppSpec(&AppSpec{})
+	input, err := codeDeployContext.CreateDeploymentInput("a", "d",
+		WithDeploymentConfigName("CodeDeployDefault.ECSAllAtOnce"),
+		WithAutoRollback(types.AutoRollbackEventDeploymentFailure),
+		WithAlarms("alarm"),
+		WithDescription("description"),
+		WithFileExistsBehavior(types.FileExistsBehaviorOverwrite),
+		WithIgnoreApplicationStopFailures(),
+		WithUpdateOutdatedInstancesOnly(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *input.DeploymentConfigName != "CodeDeployDefault.ECSAllAtOnce" || *input.Description != "description" || input.FileExistsBehavior != types.FileExistsBehaviorOverwrite {
+		t.Errorf("unexpected input: %+v", input)
+	}
+
+	if !input.IgnoreApplicationStopFailures || !input.UpdateOutdatedInstancesOnly {
+		t.Errorf("unexpected EC2/on-premises options: %+v", input)
+	}
+
+	if !input.AutoRollbackConfiguration.Enabled || input.AutoRollbackConfiguration.Events[0] != types.AutoRollbackEventDeploymentFailure {
+		t.Errorf("unexpected auto rollback configuration: %+v", input.AutoRollbackConfiguration)
+	}
+
+	if !input.OverrideAlarmConfiguration.Enabled || *input.OverrideAlarmConfiguration.Alarms[0].Name != "alarm" {
+		t.Errorf("unexpected alarm configuration: %+v", input.OverrideAlarmConfiguration)
+	}
+
+	input, _ = codeDeployContext.CreateDeploymentInput("a", "d", WithoutAutoRollback(), WithoutAlarms())
+
+	if input.AutoRollbackConfiguration.Enabled || input.OverrideAlarmConfiguration.Enabled {
+		t.Error("unexpected enabled configuration")
+	}
+}
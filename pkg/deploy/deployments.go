@@ -0,0 +1,148 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"slices"
+	"time"
+)
+
+// batchGetDeploymentsLimit is the maximum number of deployment IDs per BatchGetDeployments request.
+const batchGetDeploymentsLimit = 25
+
+// ErrNoActiveDeployment indicates that no deployment of the deployment group is running.
+var ErrNoActiveDeployment = errors.New("no active deployment")
+
+// ActiveDeploymentStatuses are the statuses of deployments which haven't finished yet.
+var ActiveDeploymentStatuses = []types.DeploymentStatus{
+	types.DeploymentStatusCreated,
+	types.DeploymentStatusQueued,
+	types.DeploymentStatusInProgress,
+	types.DeploymentStatusBaking,
+	types.DeploymentStatusReady,
+}
+
+// ListDeployments returns the IDs of all deployments of the deployment group which have one of the given statuses.
+func ListDeployments(ctx context.Context, client CodeDeployClient, applicationName, deploymentGroupName string, statuses ...types.DeploymentStatus) ([]string, error) {
+	return listDeployments(ctx, client, &codedeploy.ListDeploymentsInput{
+		ApplicationName:     aws.String(applicationName),
+		DeploymentGroupName: aws.String(deploymentGroupName),
+		IncludeOnlyStatuses: statuses,
+	})
+}
+
+func listDeployments(ctx context.Context, client CodeDeployClient, input *codedeploy.ListDeploymentsInput) ([]string, error) {
+	var deploymentIDs []string
+
+	for {
+		output, err := client.ListDeployments(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list deployments: %w", err)
+		}
+
+		deploymentIDs = append(deploymentIDs, output.Deployments...)
+
+		if output.NextToken == nil {
+			return deploymentIDs, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// FindActiveDeployment returns the ID of the deployment which is currently running in the deployment group.
+func FindActiveDeployment(ctx context.Context, client CodeDeployClient, applicationName, deploymentGroupName string) (string, error) {
+	deploymentIDs, err := ListDeployments(ctx, client, applicationName, deploymentGroupName, ActiveDeploymentStatuses...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(deploymentIDs) == 0 {
+		return "", fmt.Errorf("application %q (group %q): %w", applicationName, deploymentGroupName, ErrNoActiveDeployment)
+	}
+
+	return deploymentIDs[0], nil
+}
+
+// GetDeployment returns the deployment info of a deployment.
+func GetDeployment(ctx context.Context, client CodeDeployClient, deploymentID string) (*types.DeploymentInfo, error) {
+	output, err := client.GetDeployment(ctx, &codedeploy.GetDeploymentInput{DeploymentId: aws.String(deploymentID)})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get deployment %q: %w", deploymentID, err)
+	}
+
+	if output.DeploymentInfo == nil {
+		return nil, fmt.Errorf("cannot get deployment %q: no deployment info returned", deploymentID)
+	}
+
+	return output.DeploymentInfo, nil
+}
+
+// RevisionAppSpecSha256 returns the AppSpec hash a deployment was created with, or an empty string for other revision types.
+func RevisionAppSpecSha256(info *types.DeploymentInfo) string {
+	if info.Revision == nil || info.Revision.AppSpecContent == nil {
+		return ""
+	}
+	return aws.ToString(info.Revision.AppSpecContent.Sha256)
+}
+
+// BatchGetDeployments returns the deployment info of all given deployments.
+func BatchGetDeployments(ctx context.Context, client CodeDeployClient, deploymentIDs []string) ([]types.DeploymentInfo, error) {
+	deployments := make([]types.DeploymentInfo, 0, len(deploymentIDs))
+
+	for start := 0; start < len(deploymentIDs); start += batchGetDeploymentsLimit {
+		end := min(start+batchGetDeploymentsLimit, len(deploymentIDs))
+
+		output, err := client.BatchGetDeployments(ctx, &codedeploy.BatchGetDeploymentsInput{DeploymentIds: deploymentIDs[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get deployments: %w", err)
+		}
+
+		deployments = append(deployments, output.DeploymentsInfo...)
+	}
+
+	return deployments, nil
+}
+
+// FindUnchangedDeployment returns the most recent deployment of the deployment group created since the given time, if it is active or succeeded and its AppSpec has the given hash.
+// It returns nil if there is no deployment, or if the most recent one deployed a different AppSpec, failed or was stopped.
+func FindUnchangedDeployment(ctx context.Context, client CodeDeployClient, applicationName, deploymentGroupName, appSpecSha256 string, since time.Time) (*types.DeploymentInfo, error) {
+	if appSpecSha256 == "" {
+		return nil, errors.New("cannot find unchanged deployment without AppSpec hash")
+	}
+
+	deploymentIDs, err := listDeployments(ctx, client, &codedeploy.ListDeploymentsInput{
+		ApplicationName:     aws.String(applicationName),
+		DeploymentGroupName: aws.String(deploymentGroupName),
+		CreateTimeRange:     &types.TimeRange{Start: aws.Time(since)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := BatchGetDeployments(ctx, client, deploymentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deployments) == 0 {
+		return nil, nil
+	}
+
+	latest := slices.MaxFunc(deployments, func(a, b types.DeploymentInfo) int {
+		return aws.ToTime(a.CreateTime).Compare(aws.ToTime(b.CreateTime))
+	})
+
+	if latest.Status != types.DeploymentStatusSucceeded && !slices.Contains(ActiveDeploymentStatuses, latest.Status) {
+		return nil, nil
+	}
+
+	if RevisionAppSpecSha256(&latest) != appSpecSha256 {
+		return nil, nil
+	}
+
+	return &latest, nil
+}
@@ -0,0 +1,191 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"testing"
+	"time"
+)
+
+func TestListDeployments(t *testing.T) {
+	client := &mockCodeDeployClient{ListDeploymentsOutputs: []*codedeploy.ListDeploymentsOutput{
+		{Deployments: []string{"d-1", "d-2"}, NextToken: aws.String("1")},
+		{Deployments: []string{"d-3"}},
+	}}
+
+	deploymentIDs, err := ListDeployments(context.Background(), client, "app", "group", ActiveDeploymentStatuses...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deploymentIDs) != 3 || deploymentIDs[2] != "d-3" {
+		t.Errorf("unexpected deployment IDs: %v", deploymentIDs)
+	}
+
+	if input := client.ListDeploymentsInputs[0]; *input.ApplicationName != "app" || *input.DeploymentGroupName != "group" || len(input.IncludeOnlyStatuses) != len(ActiveDeploymentStatuses) {
+		t.Error("unexpected input")
+	}
+}
+
+func TestFindActiveDeployment(t *testing.T) {
+	client := &mockCodeDeployClient{ListDeploymentsOutputs: []*codedeploy.ListDeploymentsOutput{{Deployments: []string{"d-1"}}}}
+
+	deploymentID, err := FindActiveDeployment(context.Background(), client, "app", "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deploymentID != "d-1" {
+		t.Error("unexpected deployment ID")
+	}
+}
+
+func TestFindActiveDeployment_error(t *testing.T) {
+	if _, err := FindActiveDeployment(context.Background(), &mockCodeDeployClient{}, "app", "group"); !errors.Is(err, ErrNoActiveDeployment) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := FindActiveDeployment(context.Background(), &mockCodeDeployClient{ListDeploymentsErr: errors.New("mock")}, "app", "group"); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestGetDeployment(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutput: &codedeploy.GetDeploymentOutput{DeploymentInfo: &types.DeploymentInfo{
+		Revision: &types.RevisionLocation{AppSpecContent: &types.AppSpecContent{Sha256: aws.String("hash")}},
+	}}}
+
+	info, err := GetDeployment(context.Background(), client, "d-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if RevisionAppSpecSha256(info) != "hash" {
+		t.Error("unexpected hash")
+	}
+
+	if _, err := GetDeployment(context.Background(), &mockCodeDeployClient{GetDeploymentOutput: &codedeploy.GetDeploymentOutput{}}, "d-1"); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestRevisionAppSpecSha256(t *testing.T) {
+	if RevisionAppSpecSha256(&types.DeploymentInfo{Revision: &types.RevisionLocation{RevisionType: types.RevisionLocationTypeS3}}) != "" {
+		t.Error("unexpected hash")
+	}
+}
+
+func newMockDeploymentInfo(deploymentID string, status types.DeploymentStatus, createTime time.Time, appSpecSha256 string) types.DeploymentInfo {
+	return types.DeploymentInfo{
+		DeploymentId: aws.String(deploymentID),
+		Status:       status,
+		CreateTime:   aws.Time(createTime),
+		Revision:     &types.RevisionLocation{AppSpecContent: &types.AppSpecContent{Sha256: aws.String(appSpecSha256)}},
+	}
+}
+
+func TestFindUnchangedDeployment(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name             string
+		deployments      []types.DeploymentInfo
+		wantDeploymentID string
+	}{
+		{
+			name: "latest succeeded",
+			deployments: []types.DeploymentInfo{
+				newMockDeploymentInfo("d-1", types.DeploymentStatusSucceeded, now.Add(-2*time.Hour), "other"),
+				newMockDeploymentInfo("d-2", types.DeploymentStatusSucceeded, now.Add(-time.Hour), "hash"),
+			},
+			wantDeploymentID: "d-2",
+		},
+		{
+			name: "in progress",
+			deployments: []types.DeploymentInfo{
+				newMockDeploymentInfo("d-1", types.DeploymentStatusInProgress, now, "hash"),
+				newMockDeploymentInfo("d-2", types.DeploymentStatusSucceeded, now.Add(-time.Hour), "other"),
+			},
+			wantDeploymentID: "d-1",
+		},
+		{
+			name: "superseded",
+			deployments: []types.DeploymentInfo{
+				newMockDeploymentInfo("d-1", types.DeploymentStatusSucceeded, now.Add(-2*time.Hour), "hash"),
+				newMockDeploymentInfo("d-2", types.DeploymentStatusSucceeded, now.Add(-time.Hour), "other"),
+			},
+		},
+		{
+			name: "failed after succeeded",
+			deployments: []types.DeploymentInfo{
+				newMockDeploymentInfo("d-1", types.DeploymentStatusSucceeded, now.Add(-2*time.Hour), "hash"),
+				newMockDeploymentInfo("d-2", types.DeploymentStatusFailed, now.Add(-time.Hour), "other"),
+			},
+		},
+		{
+			name: "latest stopped",
+			deployments: []types.DeploymentInfo{
+				newMockDeploymentInfo("d-1", types.DeploymentStatusStopped, now.Add(-time.Hour), "hash"),
+			},
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deploymentIDs []string
+			for _, deployment := range tt.deployments {
+				deploymentIDs = append(deploymentIDs, *deployment.DeploymentId)
+			}
+
+			client := &mockCodeDeployClient{
+				ListDeploymentsOutputs:    []*codedeploy.ListDeploymentsOutput{{Deployments: deploymentIDs}},
+				BatchGetDeploymentsOutput: &codedeploy.BatchGetDeploymentsOutput{DeploymentsInfo: tt.deployments},
+			}
+
+			got, err := FindUnchangedDeployment(context.Background(), client, "app", "group", "hash", now.Add(-24*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotDeploymentID string
+			if got != nil {
+				gotDeploymentID = *got.DeploymentId
+			}
+			if gotDeploymentID != tt.wantDeploymentID {
+				t.Errorf("FindUnchangedDeployment() got = %v, want %v", gotDeploymentID, tt.wantDeploymentID)
+			}
+
+			if input := client.ListDeploymentsInputs[0]; input.CreateTimeRange == nil || input.IncludeOnlyStatuses != nil {
+				t.Error("unexpected input")
+			}
+		})
+	}
+}
+
+func TestFindUnchangedDeployment_withoutHash(t *testing.T) {
+	if _, err := FindUnchangedDeployment(context.Background(), &mockCodeDeployClient{}, "app", "group", "", time.Now()); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestBatchGetDeployments(t *testing.T) {
+	deploymentIDs := make([]string, 30)
+	client := &mockCodeDeployClient{}
+
+	if _, err := BatchGetDeployments(context.Background(), client, deploymentIDs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.BatchGetDeploymentsInputs) != 2 || len(client.BatchGetDeploymentsInputs[1].DeploymentIds) != 5 {
+		t.Error("unexpected batches")
+	}
+
+	if _, err := BatchGetDeployments(context.Background(), &mockCodeDeployClient{BatchGetDeploymentsErr: errors.New("mock")}, deploymentIDs); err == nil {
+		t.Error("no error")
+	}
+}
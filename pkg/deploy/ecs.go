@@ -0,0 +1,204 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"strings"
+)
+
+type ECSClient interface {
+	DescribeTaskDefinition(ctx context.Context, params *ecs.DescribeTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error)
+	RegisterTaskDefinition(ctx context.Context, params *ecs.RegisterTaskDefinitionInput, optFns ...func(*ecs.Options)) (*ecs.RegisterTaskDefinitionOutput, error)
+}
+
+// DescribeTaskDefinition returns a task definition by ARN, family or family:revision.
+// A family resolves to its latest active revision.
+func DescribeTaskDefinition(ctx context.Context, client ECSClient, taskDefinition string) (*types.TaskDefinition, error) {
+	output, err := client.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String(taskDefinition)})
+	if err != nil {
+		return nil, fmt.Errorf("cannot describe task definition %q: %w", taskDefinition, err)
+	}
+
+	if output.TaskDefinition == nil || output.TaskDefinition.TaskDefinitionArn == nil {
+		return nil, fmt.Errorf("task definition %q not found", taskDefinition)
+	}
+
+	return output.TaskDefinition, nil
+}
+
+// findLoadBalancerInfo completes the container name and port from the port mappings of the task definition.
+// Either value is only defaulted if there is exactly one candidate.
+func findLoadBalancerInfo(taskDefinition *types.TaskDefinition, containerName string, containerPort int) (LoadBalancerInfo, error) {
+	if len(containerName) > 0 && containerPort > 0 {
+		return LoadBalancerInfo{ContainerName: containerName, ContainerPort: containerPort}, nil
+	}
+
+	var candidates []LoadBalancerInfo
+
+	for _, container := range taskDefinition.ContainerDefinitions {
+		name := aws.ToString(container.Name)
+		if len(containerName) > 0 && name != containerName {
+			continue
+		}
+
+		for _, portMapping := range container.PortMappings {
+			port := int(aws.ToInt32(portMapping.ContainerPort))
+			if port == 0 || (containerPort > 0 && port != containerPort) {
+				continue
+			}
+			candidates = append(candidates, LoadBalancerInfo{ContainerName: name, ContainerPort: port})
+		}
+	}
+
+	if len(candidates) != 1 {
+		described := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			described = append(described, fmt.Sprintf("%s:%d", candidate.ContainerName, candidate.ContainerPort))
+		}
+		return LoadBalancerInfo{}, fmt.Errorf("cannot determine container name and port of task definition %q: found %d candidates [%s], please specify them explicitly", aws.ToString(taskDefinition.TaskDefinitionArn), len(candidates), strings.Join(described, ", "))
+	}
+
+	return candidates[0], nil
+}
+
+// describedTaskDefinition is the JSON format of "aws ecs describe-task-definition".
+type describedTaskDefinition struct {
+	TaskDefinition json.RawMessage `json:"taskDefinition"`
+	Tags           []types.Tag     `json:"tags"`
+}
+
+// ParseTaskDefinitionTemplate reads a task definition in the JSON format of "aws ecs register-task-definition --cli-input-json",
+// or the output of "aws ecs describe-task-definition", whose task definition is unwrapped and whose read-only attributes are ignored.
+func ParseTaskDefinitionTemplate(data []byte) (*ecs.RegisterTaskDefinitionInput, error) {
+	var described describedTaskDefinition
+	if err := json.Unmarshal(data, &described); err != nil {
+		return nil, fmt.Errorf("cannot parse task definition template: %w", err)
+	}
+
+	if len(described.TaskDefinition) > 0 {
+		data = described.TaskDefinition
+	}
+
+	var input ecs.RegisterTaskDefinitionInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("cannot parse task definition template: %w", err)
+	}
+
+	if len(input.Tags) == 0 {
+		input.Tags = described.Tags
+	}
+
+	if len(aws.ToString(input.Family)) == 0 {
+		return nil, errors.New("task definition template has no family")
+	}
+
+	if len(input.ContainerDefinitions) == 0 {
+		return nil, errors.New("task definition template has no container definitions")
+	}
+
+	return &input, nil
+}
+
+// CurrentTaskDefinitionInput returns a RegisterTaskDefinition request which copies the given task definition including its tags.
+func CurrentTaskDefinitionInput(ctx context.Context, client ECSClient, taskDefinition string) (*ecs.RegisterTaskDefinitionInput, error) {
+	output, err := client.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinition),
+		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot describe task definition %q: %w", taskDefinition, err)
+	}
+
+	current := output.TaskDefinition
+	if current == nil {
+		return nil, fmt.Errorf("task definition %q not found", taskDefinition)
+	}
+
+	input := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    current.ContainerDefinitions,
+		Family:                  current.Family,
+		Cpu:                     current.Cpu,
+		EnableFaultInjection:    current.EnableFaultInjection,
+		EphemeralStorage:        current.EphemeralStorage,
+		ExecutionRoleArn:        current.ExecutionRoleArn,
+		InferenceAccelerators:   current.InferenceAccelerators,
+		IpcMode:                 current.IpcMode,
+		Memory:                  current.Memory,
+		NetworkMode:             current.NetworkMode,
+		PidMode:                 current.PidMode,
+		PlacementConstraints:    current.PlacementConstraints,
+		ProxyConfiguration:      current.ProxyConfiguration,
+		RequiresCompatibilities: current.RequiresCompatibilities,
+		RuntimePlatform:         current.RuntimePlatform,
+		TaskRoleArn:             current.TaskRoleArn,
+		Volumes:                 current.Volumes,
+	}
+
+	if len(output.Tags) > 0 {
+		input.Tags = output.Tags
+	}
+
+	return input, nil
+}
+
+// SetContainerImage replaces the image of a container in the RegisterTaskDefinition request.
+// If containerName is empty, the task definition must contain exactly one container.
+func SetContainerImage(input *ecs.RegisterTaskDefinitionInput, containerName, image string) error {
+	if len(containerName) == 0 {
+		if len(input.ContainerDefinitions) != 1 {
+			return fmt.Errorf("cannot determine container of task definition %q: found %d containers, please specify the container name explicitly", aws.ToString(input.Family), len(input.ContainerDefinitions))
+		}
+		input.ContainerDefinitions[0].Image = aws.String(image)
+		return nil
+	}
+
+	for i := range input.ContainerDefinitions {
+		if aws.ToString(input.ContainerDefinitions[i].Name) == containerName {
+			input.ContainerDefinitions[i].Image = aws.String(image)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("container %q not found in task definition %q", containerName, aws.ToString(input.Family))
+}
+
+// RegisterTaskDefinition registers a new revision of the task definition.
+func RegisterTaskDefinition(ctx context.Context, client ECSClient, input *ecs.RegisterTaskDefinitionInput) (*types.TaskDefinition, error) {
+	output, err := client.RegisterTaskDefinition(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("cannot register task definition %q: %w", aws.ToString(input.Family), err)
+	}
+
+	if output.TaskDefinition == nil || output.TaskDefinition.TaskDefinitionArn == nil {
+		return nil, fmt.Errorf("registering task definition %q did not return an ARN", aws.ToString(input.Family))
+	}
+
+	return output.TaskDefinition, nil
+}
+
+// NewECSFromRegisteredTaskDefinition creates a new instance of AppSpec like NewECS for a task definition returned by ECS.
+// If the container name or port is empty, it defaults to the only container port mapping of the task definition.
+func NewECSFromRegisteredTaskDefinition(taskDefinition *types.TaskDefinition, containerName string, containerPort int, options ...ECSOption) (*AppSpec, error) {
+	loadBalancerInfo, err := findLoadBalancerInfo(taskDefinition, containerName, containerPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewECS(*taskDefinition.TaskDefinitionArn, loadBalancerInfo.ContainerName, loadBalancerInfo.ContainerPort, options...), nil
+}
+
+// NewECSFromTaskDefinition creates a new instance of AppSpec like NewECS, but resolves the task definition ARN from a family or family:revision.
+// If the container name or port is empty, it defaults to the only container port mapping of the task definition.
+func NewECSFromTaskDefinition(ctx context.Context, client ECSClient, taskDefinition, containerName string, containerPort int, options ...ECSOption) (*AppSpec, error) {
+	resolvedTaskDefinition, err := DescribeTaskDefinition(ctx, client, taskDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewECSFromRegisteredTaskDefinition(resolvedTaskDefinition, containerName, containerPort, options...)
+}
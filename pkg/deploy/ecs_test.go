@@ -0,0 +1,276 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"testing"
+)
+
+type mockECSClient struct {
+	DescribeTaskDefinitionInput  *ecs.DescribeTaskDefinitionInput
+	DescribeTaskDefinitionOutput *ecs.DescribeTaskDefinitionOutput
+	DescribeTaskDefinitionErr    error
+	RegisterTaskDefinitionInput  *ecs.RegisterTaskDefinitionInput
+	RegisterTaskDefinitionOutput *ecs.RegisterTaskDefinitionOutput
+	RegisterTaskDefinitionErr    error
+}
+
+func (m *mockECSClient) DescribeTaskDefinition(_ context.Context, params *ecs.DescribeTaskDefinitionInput, _ ...func(*ecs.Options)) (*ecs.DescribeTaskDefinitionOutput, error) {
+	m.DescribeTaskDefinitionInput = params
+	return m.DescribeTaskDefinitionOutput, m.DescribeTaskDefinitionErr
+}
+
+func (m *mockECSClient) RegisterTaskDefinition(_ context.Context, params *ecs.RegisterTaskDefinitionInput, _ ...func(*ecs.Options)) (*ecs.RegisterTaskDefinitionOutput, error) {
+	m.RegisterTaskDefinitionInput = params
+	return m.RegisterTaskDefinitionOutput, m.RegisterTaskDefinitionErr
+}
+
+func newMockTaskDefinition(containers ...types.ContainerDefinition) *types.TaskDefinition {
+	return &types.TaskDefinition{
+		TaskDefinitionArn:    aws.String("arn:aws:ecs:eu-central-1:123456789012:task-definition/app:42"),
+		Family:               aws.String("app"),
+		Revision:             42,
+		ContainerDefinitions: containers,
+	}
+}
+
+func newMockContainer(name string, ports ...int32) types.ContainerDefinition {
+	container := types.ContainerDefinition{Name: aws.String(name)}
+	for _, port := range ports {
+		container.PortMappings = append(container.PortMappings, types.PortMapping{ContainerPort: aws.Int32(port)})
+	}
+	return container
+}
+
+func TestNewECSFromTaskDefinition(t *testing.T) {
+	client := &mockECSClient{DescribeTaskDefinitionOutput: &ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: newMockTaskDefinition(newMockContainer("app", 1337), newMockContainer("sidecar")),
+	}}
+
+	appSpec, err := NewECSFromTaskDefinition(context.Background(), client, "app", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *client.DescribeTaskDefinitionInput.TaskDefinition != "app" {
+		t.Error("unexpected task definition input")
+	}
+
+	properties := appSpec.Resources[0].TargetService.Properties.(ECSProperties)
+	if properties.TaskDefinition != "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:42" || properties.LoadBalancerInfo != (LoadBalancerInfo{ContainerName: "app", ContainerPort: 1337}) {
+		t.Errorf("unexpected properties: %+v", properties)
+	}
+}
+
+func TestNewECSFromTaskDefinition_error(t *testing.T) {
+	client := &mockECSClient{DescribeTaskDefinitionErr: errors.New("mock")}
+
+	if _, err := NewECSFromTaskDefinition(context.Background(), client, "app", "", 0); err == nil {
+		t.Error("no error")
+	}
+}
+
+func Test_findLoadBalancerInfo(t *testing.T) {
+	tests := []struct {
+		name           string
+		taskDefinition *types.TaskDefinition
+		containerName  string
+		containerPort  int
+		want           LoadBalancerInfo
+		wantErr        bool
+	}{
+		{
+			name:           "explicit",
+			taskDefinition: newMockTaskDefinition(),
+			containerName:  "app",
+			containerPort:  1337,
+			want:           LoadBalancerInfo{ContainerName: "app", ContainerPort: 1337},
+		},
+		{
+			name:           "single port",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app", 1337)),
+			want:           LoadBalancerInfo{ContainerName: "app", ContainerPort: 1337},
+		},
+		{
+			name:           "port of named container",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app", 1337), newMockContainer("proxy", 8080)),
+			containerName:  "proxy",
+			want:           LoadBalancerInfo{ContainerName: "proxy", ContainerPort: 8080},
+		},
+		{
+			name:           "container of given port",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app", 1337), newMockContainer("proxy", 8080)),
+			containerPort:  8080,
+			want:           LoadBalancerInfo{ContainerName: "proxy", ContainerPort: 8080},
+		},
+		{
+			name:           "ambiguous containers",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app", 1337), newMockContainer("proxy", 8080)),
+			wantErr:        true,
+		},
+		{
+			name:           "ambiguous ports",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app", 1337, 9090)),
+			containerName:  "app",
+			wantErr:        true,
+		},
+		{
+			name:           "no port",
+			taskDefinition: newMockTaskDefinition(newMockContainer("app")),
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findLoadBalancerInfo(tt.taskDefinition, tt.containerName, tt.containerPort)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findLoadBalancerInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("findLoadBalancerInfo() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskDefinitionTemplate(t *testing.T) {
+	input, err := ParseTaskDefinitionTemplate([]byte(`{
+		"family": "app",
+		"taskDefinitionArn": "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:41",
+		"networkMode": "awsvpc",
+		"containerDefinitions": [{"name": "app", "image": "app:1", "portMappings": [{"containerPort": 1337}]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *input.Family != "app" || input.NetworkMode != types.NetworkModeAwsvpc || *input.ContainerDefinitions[0].PortMappings[0].ContainerPort != 1337 {
+		t.Errorf("unexpected input: %+v", input)
+	}
+}
+
+func TestParseTaskDefinitionTemplate_describeTaskDefinitionOutput(t *testing.T) {
+	input, err := ParseTaskDefinitionTemplate([]byte(`{
+		"taskDefinition": {
+			"family": "app",
+			"revision": 41,
+			"status": "ACTIVE",
+			"containerDefinitions": [{"name": "app", "image": "app:1"}]
+		},
+		"tags": [{"key": "team", "value": "a"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *input.Family != "app" || len(input.ContainerDefinitions) != 1 || len(input.Tags) != 1 || *input.Tags[0].Key != "team" {
+		t.Errorf("unexpected input: %+v", input)
+	}
+}
+
+func TestParseTaskDefinitionTemplate_error(t *testing.T) {
+	for _, data := range []string{`{`, `{"containerDefinitions": [{"name": "app"}]}`, `{"family": "app"}`, `{"taskDefinition": {"family": "app"}}`} {
+		if _, err := ParseTaskDefinitionTemplate([]byte(data)); err == nil {
+			t.Errorf("no error for %s", data)
+		}
+	}
+}
+
+func TestCurrentTaskDefinitionInput(t *testing.T) {
+	client := &mockECSClient{DescribeTaskDefinitionOutput: &ecs.DescribeTaskDefinitionOutput{
+		TaskDefinition: newMockTaskDefinition(newMockContainer("app", 1337)),
+		Tags:           []types.Tag{{Key: aws.String("team"), Value: aws.String("a")}},
+	}}
+
+	input, err := CurrentTaskDefinitionInput(context.Background(), client, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.DescribeTaskDefinitionInput.Include) != 1 {
+		t.Error("tags not included")
+	}
+
+	if *input.Family != "app" || len(input.ContainerDefinitions) != 1 || len(input.Tags) != 1 {
+		t.Errorf("unexpected input: %+v", input)
+	}
+}
+
+func TestSetContainerImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		containers    []types.ContainerDefinition
+		containerName string
+		wantIndex     int
+		wantErr       bool
+	}{
+		{
+			name:          "named container",
+			containers:    []types.ContainerDefinition{newMockContainer("app", 1337), newMockContainer("proxy", 8080)},
+			containerName: "proxy",
+			wantIndex:     1,
+		},
+		{
+			name:       "single container",
+			containers: []types.ContainerDefinition{newMockContainer("app", 1337)},
+			wantIndex:  0,
+		},
+		{
+			name:       "ambiguous container",
+			containers: []types.ContainerDefinition{newMockContainer("app", 1337), newMockContainer("proxy", 8080)},
+			wantErr:    true,
+		},
+		{
+			name:          "unknown container",
+			containers:    []types.ContainerDefinition{newMockContainer("app", 1337)},
+			containerName: "proxy",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &ecs.RegisterTaskDefinitionInput{Family: aws.String("app"), ContainerDefinitions: tt.containers}
+
+			err := SetContainerImage(input, tt.containerName, "app:2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetContainerImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && aws.ToString(input.ContainerDefinitions[tt.wantIndex].Image) != "app:2" {
+				t.Error("image not replaced")
+			}
+		})
+	}
+}
+
+func TestRegisterTaskDefinition(t *testing.T) {
+	client := &mockECSClient{RegisterTaskDefinitionOutput: &ecs.RegisterTaskDefinitionOutput{
+		TaskDefinition: newMockTaskDefinition(newMockContainer("app", 1337)),
+	}}
+	input := &ecs.RegisterTaskDefinitionInput{Family: aws.String("app")}
+
+	taskDefinition, err := RegisterTaskDefinition(context.Background(), client, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.RegisterTaskDefinitionInput != input || *taskDefinition.TaskDefinitionArn != "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:42" {
+		t.Error("unexpected task definition")
+	}
+}
+
+func TestRegisterTaskDefinition_error(t *testing.T) {
+	client := &mockECSClient{RegisterTaskDefinitionErr: errors.New("mock")}
+
+	if _, err := RegisterTaskDefinition(context.Background(), client, &ecs.RegisterTaskDefinitionInput{}); err == nil {
+		t.Error("no error")
+	}
+
+	client = &mockECSClient{RegisterTaskDefinitionOutput: &ecs.RegisterTaskDefinitionOutput{}}
+
+	if _, err := RegisterTaskDefinition(context.Background(), client, &ecs.RegisterTaskDefinitionInput{}); err == nil {
+		t.Error("no error")
+	}
+}
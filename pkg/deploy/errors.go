@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"strings"
+)
+
+// ErrNoRollbackDeployment indicates that CodeDeploy didn't create a rollback deployment for a failed deployment.
+var ErrNoRollbackDeployment = errors.New("no rollback deployment")
+
+// ErrRollbackFailed indicates that the rollback deployment of a failed deployment didn't succeed either.
+var ErrRollbackFailed = errors.New("rollback failed")
+
+// DeploymentFailedError describes a deployment which did not succeed, as reported by CodeDeploy.
+type DeploymentFailedError struct {
+	DeploymentID string
+	Status       types.DeploymentStatus
+	Code         types.ErrorCode
+	Message      string
+	RollbackInfo *types.RollbackInfo
+	// TargetID and LifecycleEvent identify the first failed lifecycle event, if any.
+	TargetID       string
+	LifecycleEvent *types.LifecycleEvent
+	// Err is the error returned while waiting for the deployment.
+	Err error
+}
+
+func (e *DeploymentFailedError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "deployment %q", e.DeploymentID)
+	if len(e.Status) > 0 {
+		fmt.Fprintf(&b, " is %s", e.Status)
+	} else {
+		b.WriteString(" failed")
+	}
+
+	if len(e.Message) > 0 {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+
+	if len(e.Code) > 0 {
+		fmt.Fprintf(&b, " [%s]", e.Code)
+	}
+
+	if e.LifecycleEvent != nil {
+		fmt.Fprintf(&b, "; lifecycle event %s of target %q failed", aws.ToString(e.LifecycleEvent.LifecycleEventName), e.TargetID)
+	}
+
+	if e.RollbackInfo != nil && e.RollbackInfo.RollbackDeploymentId != nil {
+		fmt.Fprintf(&b, "; rolled back by deployment %q", *e.RollbackInfo.RollbackDeploymentId)
+	}
+
+	if e.Err != nil {
+		fmt.Fprintf(&b, " (%s)", e.Err)
+	}
+
+	return b.String()
+}
+
+func (e *DeploymentFailedError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackDeploymentID returns the ID of the deployment which rolls back the failed deployment, or an empty string.
+func (e *DeploymentFailedError) RollbackDeploymentID() string {
+	if e.RollbackInfo == nil {
+		return ""
+	}
+	return aws.ToString(e.RollbackInfo.RollbackDeploymentId)
+}
+
+// newDeploymentFailedError collects the failure details of a deployment.
+// It returns nil if CodeDeploy doesn't report the deployment as failed or stopped, e.g. because it is still in progress.
+func newDeploymentFailedError(ctx context.Context, client CodeDeployClient, deploymentID string, info *types.DeploymentInfo, err error) *DeploymentFailedError {
+	if info.Status != types.DeploymentStatusFailed && info.Status != types.DeploymentStatusStopped && info.ErrorInformation == nil {
+		return nil
+	}
+
+	failedErr := &DeploymentFailedError{
+		DeploymentID: deploymentID,
+		Status:       info.Status,
+		RollbackInfo: info.RollbackInfo,
+		Err:          err,
+	}
+
+	if info.ErrorInformation != nil {
+		failedErr.Code = info.ErrorInformation.Code
+		failedErr.Message = aws.ToString(info.ErrorInformation.Message)
+	}
+
+	// The lifecycle event is an optional detail, so errors of the target APIs are ignored.
+	if targets, targetsErr := GetDeploymentTargets(ctx, client, failedErr.DeploymentID); targetsErr == nil {
+		for _, target := range targets {
+			if lifecycleEvent := target.FailedLifecycleEvent(); lifecycleEvent != nil {
+				failedErr.TargetID = target.TargetID
+				failedErr.LifecycleEvent = lifecycleEvent
+				break
+			}
+		}
+	}
+
+	return failedErr
+}
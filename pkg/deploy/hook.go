@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+type HookName string
+
+const (
+	BeforeInstallHook         HookName = "BeforeInstall"
+	AfterInstallHook          HookName = "AfterInstall"
+	AfterAllowTestTrafficHook HookName = "AfterAllowTestTraffic"
+	BeforeAllowTrafficHook    HookName = "BeforeAllowTraffic"
+	AfterAllowTrafficHook     HookName = "AfterAllowTraffic"
+)
+
+var hookNames = map[TargetServiceType][]HookName{
+	ECSTargetServiceType:    {BeforeInstallHook, AfterInstallHook, AfterAllowTestTrafficHook, BeforeAllowTrafficHook, AfterAllowTrafficHook},
+	LambdaTargetServiceType: {BeforeAllowTrafficHook, AfterAllowTrafficHook},
+}
+
+// HookNames returns the lifecycle event hooks supported by a target service type in the order they are run.
+func HookNames(targetServiceType TargetServiceType) []HookName {
+	return slices.Clone(hookNames[targetServiceType])
+}
+
+func checkHookName(targetServiceType TargetServiceType, name HookName) error {
+	if !slices.Contains(hookNames[targetServiceType], name) {
+		return fmt.Errorf("hook %q is not supported for %q", name, targetServiceType)
+	}
+	return nil
+}
+
+// Hook refers to a Lambda function which is invoked during a lifecycle event of the deployment.
+type Hook struct {
+	Name         HookName
+	FunctionName string
+}
+
+func (h Hook) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[HookName]string{h.Name: h.FunctionName})
+}
+
+func (h *Hook) UnmarshalJSON(data []byte) error {
+	var hook map[HookName]string
+	if err := json.Unmarshal(data, &hook); err != nil {
+		return err
+	}
+
+	if len(hook) != 1 {
+		return fmt.Errorf("hook must contain exactly one lifecycle event, got %d", len(hook))
+	}
+
+	for name, functionName := range hook {
+		h.Name = name
+		h.FunctionName = functionName
+	}
+
+	return nil
+}
+
+// AddHook adds a lifecycle event hook, provided that every target service of the AppSpec supports it.
+func (a *AppSpec) AddHook(name HookName, functionName string) error {
+	if len(functionName) == 0 {
+		return fmt.Errorf("hook %q requires a function name", name)
+	}
+
+	for _, resource := range a.Resources {
+		if err := checkHookName(resource.TargetService.Type, name); err != nil {
+			return err
+		}
+	}
+
+	a.Hooks = append(a.Hooks, Hook{Name: name, FunctionName: functionName})
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package deploy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAppSpec_AddHook(t *testing.T) {
+	appSpec := NewECS("this:is:the:arn", "containerName", 1337)
+
+	if err := appSpec.AddHook(AfterAllowTestTrafficHook, "arn:aws:lambda:eu-central-1:123456789012:function:validate"); err != nil {
+		t.Error(err)
+	}
+
+	result, err := appSpec.MarshalJSON()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := `{"version":"0.0","Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"this:is:the:arn","LoadBalancerInfo":{"ContainerName":"containerName","ContainerPort":1337}}}}],"Hooks":[{"AfterAllowTestTraffic":"arn:aws:lambda:eu-central-1:123456789012:function:validate"}]}`
+	if string(result) != want {
+		t.Error("resulting JSON is wrong")
+	}
+}
+
+func TestAppSpec_AddHook_unsupported(t *testing.T) {
+	appSpec := NewLambda("function-name", "function-alias", "42", "43")
+
+	if err := appSpec.AddHook(BeforeInstallHook, "validate"); err == nil {
+		t.Error("no err")
+	}
+
+	if err := appSpec.AddHook(BeforeAllowTrafficHook, ""); err == nil {
+		t.Error("no err")
+	}
+
+	if len(appSpec.Hooks) != 0 {
+		t.Error("unexpected hooks")
+	}
+}
+
+func TestHook_UnmarshalJSON(t *testing.T) {
+	var hooks []Hook
+	if err := json.Unmarshal([]byte(`[{"BeforeAllowTraffic":"before"},{"AfterAllowTraffic":"after"}]`), &hooks); err != nil {
+		t.Error(err)
+	}
+
+	if len(hooks) != 2 || hooks[0] != (Hook{Name: BeforeAllowTrafficHook, FunctionName: "before"}) || hooks[1] != (Hook{Name: AfterAllowTrafficHook, FunctionName: "after"}) {
+		t.Error("unexpected hooks")
+	}
+}
+
+func TestHook_UnmarshalJSON_error(t *testing.T) {
+	var hook Hook
+	if err := json.Unmarshal([]byte(`{"BeforeAllowTraffic":"before","AfterAllowTraffic":"after"}`), &hook); err == nil {
+		t.Error("no err")
+	}
+}
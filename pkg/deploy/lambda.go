@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// ErrAlreadyDeployed indicates that the target version is already live.
+var ErrAlreadyDeployed = errors.New("target version is already deployed")
+
+type LambdaClient interface {
+	GetAlias(ctx context.Context, params *lambda.GetAliasInput, optFns ...func(*lambda.Options)) (*lambda.GetAliasOutput, error)
+	PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error)
+}
+
+// PublishVersion publishes a new version of the function and returns its version number.
+// If codeSha256 is not empty, the function is only published if its code has this SHA256 hash (base64-encoded).
+func PublishVersion(ctx context.Context, client LambdaClient, functionName, codeSha256 string) (string, error) {
+	input := &lambda.PublishVersionInput{FunctionName: aws.String(functionName)}
+	if len(codeSha256) > 0 {
+		input.CodeSha256 = aws.String(codeSha256)
+	}
+
+	version, err := client.PublishVersion(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("cannot publish version of function %q: %w", functionName, err)
+	}
+
+	if version.Version == nil {
+		return "", fmt.Errorf("publishing function %q did not return a version", functionName)
+	}
+
+	return *version.Version, nil
+}
+
+// ResolveAliasVersion returns the function version an alias currently points to.
+func ResolveAliasVersion(ctx context.Context, client LambdaClient, functionName, functionAlias string) (string, error) {
+	alias, err := client.GetAlias(ctx, &lambda.GetAliasInput{FunctionName: aws.String(functionName), Name: aws.String(functionAlias)})
+	if err != nil {
+		return "", fmt.Errorf("cannot get alias %q of function %q: %w", functionAlias, functionName, err)
+	}
+
+	if alias.FunctionVersion == nil {
+		return "", fmt.Errorf("alias %q of function %q does not point to any version", functionAlias, functionName)
+	}
+
+	return *alias.FunctionVersion, nil
+}
+
+// NewLambdaFromAlias creates a new instance of AppSpec like NewLambda, but uses the version the alias currently points to as current version.
+func NewLambdaFromAlias(ctx context.Context, client LambdaClient, functionName, functionAlias, targetVersion string) (*AppSpec, error) {
+	currentVersion, err := ResolveAliasVersion(ctx, client, functionName, functionAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	if currentVersion == targetVersion {
+		return nil, fmt.Errorf("alias %q of function %q already points to version %q: %w", functionAlias, functionName, targetVersion, ErrAlreadyDeployed)
+	}
+
+	return NewLambda(functionName, functionAlias, currentVersion, targetVersion), nil
+}
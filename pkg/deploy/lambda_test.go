@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"testing"
+)
+
+type mockLambdaClient struct {
+	GetAliasOutput       *lambda.GetAliasOutput
+	GetAliasErr          error
+	PublishVersionInput  *lambda.PublishVersionInput
+	PublishVersionOutput *lambda.PublishVersionOutput
+	PublishVersionErr    error
+}
+
+func (m *mockLambdaClient) GetAlias(_ context.Context, _ *lambda.GetAliasInput, _ ...func(*lambda.Options)) (*lambda.GetAliasOutput, error) {
+	return m.GetAliasOutput, m.GetAliasErr
+}
+
+func (m *mockLambdaClient) PublishVersion(_ context.Context, params *lambda.PublishVersionInput, _ ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	m.PublishVersionInput = params
+	return m.PublishVersionOutput, m.PublishVersionErr
+}
+
+func TestPublishVersion(t *testing.T) {
+	client := &mockLambdaClient{PublishVersionOutput: &lambda.PublishVersionOutput{Version: aws.String("44")}}
+
+	version, err := PublishVersion(context.Background(), client, "function-name", "c29tZS1oYXNo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "44" {
+		t.Error("unexpected version")
+	}
+
+	if *client.PublishVersionInput.FunctionName != "function-name" || *client.PublishVersionInput.CodeSha256 != "c29tZS1oYXNo" {
+		t.Error("unexpected input")
+	}
+}
+
+func TestPublishVersion_error(t *testing.T) {
+	client := &mockLambdaClient{PublishVersionErr: errors.New("mock")}
+
+	if _, err := PublishVersion(context.Background(), client, "function-name", ""); err == nil {
+		t.Error("no error")
+	}
+
+	if client.PublishVersionInput.CodeSha256 != nil {
+		t.Error("unexpected code SHA256")
+	}
+}
+
+func TestNewLambdaFromAlias(t *testing.T) {
+	client := &mockLambdaClient{GetAliasOutput: &lambda.GetAliasOutput{FunctionVersion: aws.String("42")}}
+
+	appSpec, err := NewLambdaFromAlias(context.Background(), client, "function-name", "function-alias", "43")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if properties := appSpec.Resources[0].TargetService.Properties.(LambdaProperties); properties.CurrentVersion != "42" || properties.TargetVersion != "43" {
+		t.Error("unexpected versions")
+	}
+}
+
+func TestNewLambdaFromAlias_alreadyDeployed(t *testing.T) {
+	client := &mockLambdaClient{GetAliasOutput: &lambda.GetAliasOutput{FunctionVersion: aws.String("43")}}
+
+	if _, err := NewLambdaFromAlias(context.Background(), client, "function-name", "function-alias", "43"); !errors.Is(err, ErrAlreadyDeployed) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewLambdaFromAlias_error(t *testing.T) {
+	client := &mockLambdaClient{GetAliasErr: errors.New("mock")}
+
+	if _, err := NewLambdaFromAlias(context.Background(), client, "function-name", "function-alias", "43"); err == nil {
+		t.Error("no error")
+	}
+
+	client = &mockLambdaClient{GetAliasOutput: &lambda.GetAliasOutput{}}
+
+	if _, err := NewLambdaFromAlias(context.Background(), client, "function-name", "function-alias", "43"); err == nil {
+		t.Error("no error")
+	}
+}
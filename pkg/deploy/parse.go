@@ -0,0 +1,113 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.yaml.in/yaml/v3"
+)
+
+// ParseAppSpec parses an AppSpec in either JSON or YAML format.
+// Syntax errors are reported along with their position.
+func ParseAppSpec(data []byte) (*AppSpec, error) {
+	appSpecJson, err := normalizeAppSpec(data)
+	if err != nil {
+		return nil, err
+	}
+
+	appSpec := &AppSpec{}
+	if err := unmarshalStrict(appSpecJson, appSpec); err != nil {
+		return nil, fmt.Errorf("invalid AppSpec: %w", err)
+	}
+
+	return appSpec, nil
+}
+
+func normalizeAppSpec(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("AppSpec is empty")
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, new(any)); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				line, column := position(data, syntaxErr.Offset-1)
+				return nil, fmt.Errorf("line %d, column %d: %w", line, column, err)
+			}
+			return nil, err
+		}
+		return data, nil
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	value, err := yamlNodeToValue(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// position translates a byte offset into a line and column, both starting at 1.
+func position(data []byte, offset int64) (int, int) {
+	offset = max(0, min(offset, int64(len(data))))
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
+
+// yamlNodeToValue converts a YAML node to a value which can be marshalled to JSON.
+// Numbers keep their literal representation, so that "version: 0.0" does not turn into "0".
+func yamlNodeToValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeToValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeToValue(node.Alias)
+	case yaml.MappingNode:
+		mapping := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, valueNode := node.Content[i], node.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d, column %d: mapping key must be a scalar", key.Line, key.Column)
+			}
+			if _, found := mapping[key.Value]; found {
+				return nil, fmt.Errorf("line %d, column %d: duplicate key %q", key.Line, key.Column, key.Value)
+			}
+			value, err := yamlNodeToValue(valueNode)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key.Value] = value
+		}
+		return mapping, nil
+	case yaml.SequenceNode:
+		sequence := make([]any, 0, len(node.Content))
+		for _, itemNode := range node.Content {
+			item, err := yamlNodeToValue(itemNode)
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, item)
+		}
+		return sequence, nil
+	default:
+		if tag := node.ShortTag(); (tag == "!!int" || tag == "!!float") && json.Valid([]byte(node.Value)) {
+			return json.Number(node.Value), nil
+		}
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d, column %d: %w", node.Line, node.Column, err)
+		}
+		return value, nil
+	}
+}
@@ -0,0 +1,163 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAppSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "YAML",
+			data: `version: 0.0
+Resources:
+  - TargetService:
+      Type: AWS::ECS::Service
+      Properties:
+        TaskDefinition: "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1"
+        LoadBalancerInfo:
+          ContainerName: "app"
+          ContainerPort: 1337
+Hooks:
+  - AfterAllowTestTraffic: "validate"
+`,
+		},
+		{
+			name: "JSON",
+			data: `{
+  "version": 0.0,
+  "Resources": [
+    {
+      "TargetService": {
+        "Type": "AWS::ECS::Service",
+        "Properties": {
+          "TaskDefinition": "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1",
+          "LoadBalancerInfo": {"ContainerName": "app", "ContainerPort": 1337}
+        }
+      }
+    }
+  ],
+  "Hooks": [{"AfterAllowTestTraffic": "validate"}]
+}`,
+		},
+	}
+
+	want := `{"version":"0.0","Resources":[{"TargetService":{"Type":"AWS::ECS::Service","Properties":{"TaskDefinition":"arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1","LoadBalancerInfo":{"ContainerName":"app","ContainerPort":1337}}}}],"Hooks":[{"AfterAllowTestTraffic":"validate"}]}`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appSpec, err := ParseAppSpec([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := appSpec.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(result) != want {
+				t.Errorf("resulting JSON is wrong: %s", result)
+			}
+		})
+	}
+}
+
+func TestParseAppSpec_numbers(t *testing.T) {
+	data := `version: 0.0
+Resources:
+  - my-function:
+      Type: AWS::Lambda::Function
+      Properties:
+        Name: "my-function"
+        Alias: "live"
+        CurrentVersion: 1
+        TargetVersion: 2
+  - TargetService:
+      Type: AWS::ECS::Service
+      Properties:
+        TaskDefinition: "arn:aws:ecs:eu-central-1:123456789012:task-definition/app:1"
+        LoadBalancerInfo:
+          ContainerName: "app"
+          ContainerPort: "80"
+`
+
+	appSpec, err := ParseAppSpec([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if properties, ok := appSpec.Resources[0].TargetService.Properties.(LambdaProperties); !ok || properties.CurrentVersion != "1" || properties.TargetVersion != "2" {
+		t.Errorf("unexpected Lambda properties %+v", appSpec.Resources[0].TargetService.Properties)
+	}
+
+	if properties, ok := appSpec.Resources[1].TargetService.Properties.(ECSProperties); !ok || properties.LoadBalancerInfo.ContainerPort != 80 {
+		t.Errorf("unexpected ECS properties %+v", appSpec.Resources[1].TargetService.Properties)
+	}
+}
+
+func TestParseAppSpec_resourceName(t *testing.T) {
+	appSpec, err := ParseAppSpec([]byte(`version: 0.0
+Resources:
+  - myLambdaFunction:
+      Type: AWS::Lambda::Function
+      Properties:
+        Name: "myLambdaFunction"
+        Alias: "myLambdaFunctionAlias"
+        CurrentVersion: "1"
+        TargetVersion: "2"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(appSpec.Resources) != 1 || appSpec.Resources[0].Name != "myLambdaFunction" || appSpec.Resources[0].TargetService.Type != LambdaTargetServiceType {
+		t.Error("unexpected resources")
+	}
+}
+
+func TestParseAppSpec_error(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			data:    " \n",
+			wantErr: "AppSpec is empty",
+		},
+		{
+			name:    "JSON syntax",
+			data:    "{\n  \"version\": \"0.0\",\n  \"Resources\": [,]\n}",
+			wantErr: "line 3, column 17",
+		},
+		{
+			name:    "YAML syntax",
+			data:    "version: 0.0\nResources:\n  - TargetService: a\n   b: c\n",
+			wantErr: "yaml: line 2",
+		},
+		{
+			name:    "YAML duplicate key",
+			data:    "version: 0.0\nversion: 0.0\n",
+			wantErr: "line 2, column 1: duplicate key",
+		},
+		{
+			name:    "unknown field",
+			data:    "version: 0.0\nResource: []\n",
+			wantErr: "unknown field",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAppSpec([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseAppSpec() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"time"
+)
+
+// DefaultPollInterval matches the minimum delay of the SDK's DeploymentSuccessfulWaiter.
+const DefaultPollInterval = 15 * time.Second
+
+// ErrWaitTimeout indicates that the deployment didn't finish within the maximum wait time and may still be running.
+var ErrWaitTimeout = errors.New("exceeded maximum wait time")
+
+// DeploymentEvent describes the state of a deployment observed by a single poll.
+type DeploymentEvent struct {
+	DeploymentID   string
+	Status         types.DeploymentStatus
+	PreviousStatus types.DeploymentStatus
+	Overview       types.DeploymentOverview
+	Elapsed        time.Duration
+	// TerminationWaitStarted reports whether a blue/green deployment waits before terminating the original task set or instances.
+	TerminationWaitStarted bool
+	// Targets is only populated if the poller reports targets.
+	Targets []DeploymentTarget
+	// TargetsErr is set instead of Targets if the targets could not be reported. It doesn't affect waiting for the deployment.
+	TargetsErr error
+}
+
+// StatusChanged reports whether the status differs from the previous poll.
+// The first poll always counts as a change.
+func (e DeploymentEvent) StatusChanged() bool {
+	return e.Status != e.PreviousStatus
+}
+
+// TrafficShifted reports whether the deployment succeeded, started its termination wait, or completed the AfterAllowTraffic lifecycle event on all targets which have it.
+// The latter requires the targets to be reported.
+func (e DeploymentEvent) TrafficShifted() bool {
+	if e.Status == types.DeploymentStatusSucceeded || e.TerminationWaitStarted {
+		return true
+	}
+
+	shifted := false
+	for _, target := range e.Targets {
+		for _, lifecycleEvent := range target.LifecycleEvents {
+			if aws.ToString(lifecycleEvent.LifecycleEventName) != string(AfterAllowTrafficHook) {
+				continue
+			}
+			if lifecycleEvent.Status != types.LifecycleEventStatusSucceeded {
+				return false
+			}
+			shifted = true
+		}
+	}
+
+	return shifted
+}
+
+func contextErr(ctx context.Context, maxWaitDur time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w of %s: %w", ErrWaitTimeout, maxWaitDur, ctx.Err())
+	}
+	return fmt.Errorf("waiting for deployment aborted: %w", ctx.Err())
+}
+
+type DeploymentEventHandler func(event DeploymentEvent)
+
+// DeploymentReadyHandler decides what to do with a deployment which is ready to reroute traffic.
+// Returning an error stops waiting for the deployment.
+type DeploymentReadyHandler func(ctx context.Context, event DeploymentEvent) error
+
+// DeploymentPoller waits for a deployment like the SDK's DeploymentSuccessfulWaiter, but reports every poll as DeploymentEvent.
+type DeploymentPoller struct {
+	Client       CodeDeployClient
+	Interval     time.Duration
+	EventHandler DeploymentEventHandler
+	// ReportTargets adds the deployment targets and their lifecycle events to every DeploymentEvent.
+	ReportTargets bool
+	// ReadyHandler is called once, when the deployment is waiting for traffic to be rerouted (e.g. by ContinueDeployment).
+	// Without ReadyHandler, Wait keeps waiting until the deployment group's wait time elapsed.
+	ReadyHandler DeploymentReadyHandler
+	// ReturnWhenTrafficShifted makes Wait return as soon as traffic is shifted, instead of waiting for the termination wait of blue/green deployments.
+	ReturnWhenTrafficShifted bool
+	// ContinueTerminationWait ends the termination wait of blue/green deployments right away, which terminates the original task set or instances.
+	ContinueTerminationWait bool
+}
+
+func NewDeploymentPoller(client CodeDeployClient, interval time.Duration, eventHandler DeploymentEventHandler) *DeploymentPoller {
+	return &DeploymentPoller{Client: client, Interval: interval, EventHandler: eventHandler}
+}
+
+// WithoutHandlers returns a copy of the poller which only reports events, without ReadyHandler, ReturnWhenTrafficShifted and ContinueTerminationWait.
+// It is meant for deployments which must not be interfered with, e.g. rollbacks.
+func (p *DeploymentPoller) WithoutHandlers() *DeploymentPoller {
+	return &DeploymentPoller{Client: p.Client, Interval: p.Interval, EventHandler: p.EventHandler, ReportTargets: p.ReportTargets}
+}
+
+// Wait polls the deployment until it succeeded, failed, was stopped or maxWaitDur elapsed.
+// It satisfies DeploymentSuccessfulWaiter; waiter options are ignored.
+func (p *DeploymentPoller) Wait(ctx context.Context, params *codedeploy.GetDeploymentInput, maxWaitDur time.Duration, _ ...func(*codedeploy.DeploymentSuccessfulWaiterOptions)) error {
+	readyHandled := false
+	terminationWaitContinued := false
+
+	return p.poll(ctx, *params.DeploymentId, maxWaitDur, func(ctx context.Context, event DeploymentEvent) (bool, error) {
+		switch event.Status {
+		case types.DeploymentStatusSucceeded:
+			return true, nil
+		case types.DeploymentStatusFailed, types.DeploymentStatusStopped:
+			return true, fmt.Errorf("deployment %q finished with status %q", event.DeploymentID, event.Status)
+		case types.DeploymentStatusReady:
+			if p.ReadyHandler != nil && !readyHandled {
+				readyHandled = true
+				if err := p.ReadyHandler(ctx, event); err != nil {
+					if ctx.Err() != nil {
+						return true, contextErr(ctx, maxWaitDur)
+					}
+					return true, err
+				}
+			}
+		}
+
+		if p.ContinueTerminationWait && event.TerminationWaitStarted && !terminationWaitContinued {
+			terminationWaitContinued = true
+			if err := p.Continue(ctx, event.DeploymentID, types.DeploymentWaitTypeTerminationWait); err != nil {
+				return true, err
+			}
+		}
+
+		if p.ReturnWhenTrafficShifted && event.TrafficShifted() && (terminationWaitContinued || !p.ContinueTerminationWait) {
+			return true, nil
+		}
+
+		return false, nil
+	})
+}
+
+// Continue ends the given wait of the deployment, i.e. reroutes traffic (READY_WAIT) or terminates the original instances or task set (TERMINATION_WAIT).
+// Deployments which already completed are ignored.
+func (p *DeploymentPoller) Continue(ctx context.Context, deploymentID string, waitType types.DeploymentWaitType) error {
+	_, err := p.Client.ContinueDeployment(ctx, &codedeploy.ContinueDeploymentInput{
+		DeploymentId:       aws.String(deploymentID),
+		DeploymentWaitType: waitType,
+	})
+
+	var alreadyCompletedErr *types.DeploymentAlreadyCompletedException
+	if errors.As(err, &alreadyCompletedErr) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot continue deployment: %w", err)
+	}
+
+	return nil
+}
+
+// Stop stops the deployment and waits until CodeDeploy confirms that it is no longer running.
+// With autoRollbackEnabled, CodeDeploy rolls back to the last successful revision.
+func (p *DeploymentPoller) Stop(ctx context.Context, deploymentID string, autoRollbackEnabled bool, maxWaitDur time.Duration) error {
+	_, err := p.Client.StopDeployment(ctx, &codedeploy.StopDeploymentInput{
+		DeploymentId:        aws.String(deploymentID),
+		AutoRollbackEnabled: aws.Bool(autoRollbackEnabled),
+	})
+
+	var alreadyCompletedErr *types.DeploymentAlreadyCompletedException
+	if errors.As(err, &alreadyCompletedErr) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot stop deployment: %w", err)
+	}
+
+	return p.WaitUntilFinished(ctx, deploymentID, maxWaitDur)
+}
+
+// WaitUntilFinished polls the deployment until it succeeded, failed or was stopped, and only returns an error if that didn't happen within maxWaitDur.
+func (p *DeploymentPoller) WaitUntilFinished(ctx context.Context, deploymentID string, maxWaitDur time.Duration) error {
+	return p.poll(ctx, deploymentID, maxWaitDur, func(_ context.Context, event DeploymentEvent) (bool, error) {
+		switch event.Status {
+		case types.DeploymentStatusStopped, types.DeploymentStatusFailed, types.DeploymentStatusSucceeded:
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// poll reports the deployment as DeploymentEvent until done returns true, or maxWaitDur elapsed.
+func (p *DeploymentPoller) poll(ctx context.Context, deploymentID string, maxWaitDur time.Duration, done func(ctx context.Context, event DeploymentEvent) (bool, error)) error {
+	if maxWaitDur <= 0 {
+		return errors.New("maximum wait time for deployment must be greater than zero")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxWaitDur)
+	defer cancel()
+
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	start := time.Now()
+	var previousStatus types.DeploymentStatus
+
+	for {
+		output, err := p.Client.GetDeployment(ctx, &codedeploy.GetDeploymentInput{DeploymentId: aws.String(deploymentID)})
+		if err != nil {
+			if ctx.Err() != nil {
+				return contextErr(ctx, maxWaitDur)
+			}
+			return fmt.Errorf("cannot get deployment: %w", err)
+		}
+
+		if output.DeploymentInfo == nil {
+			return errors.New("cannot get deployment: no deployment info returned")
+		}
+
+		event := DeploymentEvent{
+			DeploymentID:           deploymentID,
+			Status:                 output.DeploymentInfo.Status,
+			PreviousStatus:         previousStatus,
+			Elapsed:                time.Since(start),
+			TerminationWaitStarted: output.DeploymentInfo.InstanceTerminationWaitTimeStarted,
+		}
+		if output.DeploymentInfo.DeploymentOverview != nil {
+			event.Overview = *output.DeploymentInfo.DeploymentOverview
+		}
+		previousStatus = event.Status
+
+		if p.ReportTargets {
+			// Targets are only a diagnostic detail, so missing permissions or throttling don't abort waiting.
+			event.Targets, event.TargetsErr = GetDeploymentTargets(ctx, p.Client, event.DeploymentID)
+			if event.TargetsErr != nil && ctx.Err() != nil {
+				return contextErr(ctx, maxWaitDur)
+			}
+		}
+
+		if p.EventHandler != nil {
+			p.EventHandler(event)
+		}
+
+		if finished, err := done(ctx, event); finished {
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return contextErr(ctx, maxWaitDur)
+		case <-timer.C:
+		}
+	}
+}
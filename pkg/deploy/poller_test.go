@@ -0,0 +1,319 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"testing"
+	"time"
+)
+
+func newMockGetDeploymentOutput(status types.DeploymentStatus, succeeded int64) *codedeploy.GetDeploymentOutput {
+	return &codedeploy.GetDeploymentOutput{
+		DeploymentInfo: &types.DeploymentInfo{
+			Status:             status,
+			DeploymentOverview: &types.DeploymentOverview{Succeeded: succeeded},
+		},
+	}
+}
+
+func TestDeploymentPoller_Wait(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+		newMockGetDeploymentOutput(types.DeploymentStatusCreated, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusSucceeded, 1),
+	}}
+
+	var events []DeploymentEvent
+	poller := NewDeploymentPoller(client, time.Millisecond, func(event DeploymentEvent) {
+		events = append(events, event)
+	})
+
+	if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("unexpected number of events: %d", len(events))
+	}
+
+	var transitions []types.DeploymentStatus
+	for _, event := range events {
+		if event.DeploymentID != "mock" {
+			t.Error("unexpected deployment ID")
+		}
+		if event.StatusChanged() {
+			transitions = append(transitions, event.Status)
+		}
+	}
+
+	if len(transitions) != 3 || transitions[2] != types.DeploymentStatusSucceeded || events[3].Overview.Succeeded != 1 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestDeploymentPoller_Wait_reportTargets(t *testing.T) {
+	client := &mockCodeDeployClient{
+		GetDeploymentOutput:          newMockGetDeploymentOutput(types.DeploymentStatusSucceeded, 1),
+		ListDeploymentTargetsOutputs: []*codedeploy.ListDeploymentTargetsOutput{{TargetIds: []string{"target"}}},
+		BatchGetDeploymentTargetsOutput: &codedeploy.BatchGetDeploymentTargetsOutput{
+			DeploymentTargets: []types.DeploymentTarget{{LambdaTarget: &types.LambdaTarget{TargetId: aws.String("target")}}},
+		},
+	}
+
+	var event DeploymentEvent
+	poller := NewDeploymentPoller(client, time.Millisecond, func(e DeploymentEvent) { event = e })
+	poller.ReportTargets = true
+
+	if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(event.Targets) != 1 || event.Targets[0].TargetID != "target" {
+		t.Errorf("unexpected targets: %+v", event.Targets)
+	}
+}
+
+func TestDeploymentPoller_Wait_reportTargetsError(t *testing.T) {
+	client := &mockCodeDeployClient{
+		GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+			newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+		},
+		GetDeploymentOutput:      newMockGetDeploymentOutput(types.DeploymentStatusSucceeded, 1),
+		ListDeploymentTargetsErr: errors.New("access denied"),
+	}
+
+	var events []DeploymentEvent
+	poller := NewDeploymentPoller(client, time.Millisecond, func(e DeploymentEvent) { events = append(events, e) })
+	poller.ReportTargets = true
+
+	if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 2 || events[0].TargetsErr == nil || events[0].Targets != nil {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestDeploymentPoller_Wait_failed(t *testing.T) {
+	for _, status := range []types.DeploymentStatus{types.DeploymentStatusFailed, types.DeploymentStatusStopped} {
+		client := &mockCodeDeployClient{GetDeploymentOutput: newMockGetDeploymentOutput(status, 0)}
+
+		if err := NewDeploymentPoller(client, time.Millisecond, nil).Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err == nil {
+			t.Errorf("no error for status %q", status)
+		}
+	}
+}
+
+func TestDeploymentPoller_Wait_timeout(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutput: newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0)}
+
+	err := NewDeploymentPoller(client, time.Millisecond, nil).Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, 10*time.Millisecond)
+	if !errors.Is(err, ErrWaitTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDeploymentPoller_Wait_error(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentErr: errors.New("mock")}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestDeploymentPoller_Stop(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+		newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusStopped, 0),
+	}}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Stop(context.Background(), "mock", true, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *client.StopDeploymentInput.DeploymentId != "mock" || !*client.StopDeploymentInput.AutoRollbackEnabled {
+		t.Error("unexpected input")
+	}
+
+	if len(client.GetDeploymentOutputs) != 0 {
+		t.Error("stop not awaited")
+	}
+}
+
+func TestDeploymentPoller_Stop_alreadyCompleted(t *testing.T) {
+	client := &mockCodeDeployClient{StopDeploymentErr: &types.DeploymentAlreadyCompletedException{}}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Stop(context.Background(), "mock", false, time.Second); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDeploymentPoller_Stop_error(t *testing.T) {
+	client := &mockCodeDeployClient{StopDeploymentErr: errors.New("mock")}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Stop(context.Background(), "mock", false, time.Second); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestDeploymentPoller_Wait_ready(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+		newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusReady, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusReady, 0),
+		newMockGetDeploymentOutput(types.DeploymentStatusSucceeded, 1),
+	}}
+
+	poller := NewDeploymentPoller(client, time.Millisecond, nil)
+	poller.ReadyHandler = func(ctx context.Context, event DeploymentEvent) error {
+		return poller.Continue(ctx, event.DeploymentID, types.DeploymentWaitTypeReadyWait)
+	}
+
+	if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(client.ContinueDeploymentInputs) != 1 || *client.ContinueDeploymentInputs[0].DeploymentId != "mock" || client.ContinueDeploymentInputs[0].DeploymentWaitType != types.DeploymentWaitTypeReadyWait {
+		t.Errorf("unexpected ContinueDeployment calls: %+v", client.ContinueDeploymentInputs)
+	}
+}
+
+func TestDeploymentPoller_Wait_readyError(t *testing.T) {
+	client := &mockCodeDeployClient{GetDeploymentOutput: newMockGetDeploymentOutput(types.DeploymentStatusReady, 0)}
+	mockErr := errors.New("mock")
+
+	poller := NewDeploymentPoller(client, time.Millisecond, nil)
+	poller.ReadyHandler = func(_ context.Context, _ DeploymentEvent) error { return mockErr }
+
+	if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); !errors.Is(err, mockErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDeploymentPoller_Continue_error(t *testing.T) {
+	client := &mockCodeDeployClient{ContinueDeploymentErr: errors.New("mock")}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Continue(context.Background(), "mock", types.DeploymentWaitTypeReadyWait); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestDeploymentEvent_TrafficShifted(t *testing.T) {
+	newTarget := func(status types.LifecycleEventStatus) DeploymentTarget {
+		return DeploymentTarget{LifecycleEvents: []types.LifecycleEvent{
+			{LifecycleEventName: aws.String("BeforeAllowTraffic"), Status: types.LifecycleEventStatusSucceeded},
+			{LifecycleEventName: aws.String("AfterAllowTraffic"), Status: status},
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		event DeploymentEvent
+		want  bool
+	}{
+		{
+			name:  "succeeded",
+			event: DeploymentEvent{Status: types.DeploymentStatusSucceeded},
+			want:  true,
+		},
+		{
+			name:  "termination wait",
+			event: DeploymentEvent{Status: types.DeploymentStatusInProgress, TerminationWaitStarted: true},
+			want:  true,
+		},
+		{
+			name:  "traffic allowed",
+			event: DeploymentEvent{Status: types.DeploymentStatusInProgress, Targets: []DeploymentTarget{newTarget(types.LifecycleEventStatusSucceeded), {}}},
+			want:  true,
+		},
+		{
+			name:  "traffic partially allowed",
+			event: DeploymentEvent{Status: types.DeploymentStatusInProgress, Targets: []DeploymentTarget{newTarget(types.LifecycleEventStatusSucceeded), newTarget(types.LifecycleEventStatusInProgress)}},
+			want:  false,
+		},
+		{
+			name:  "no targets",
+			event: DeploymentEvent{Status: types.DeploymentStatusInProgress},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.TrafficShifted(); got != tt.want {
+				t.Errorf("TrafficShifted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeploymentPoller_Wait_returnWhenTrafficShifted(t *testing.T) {
+	terminationWait := newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0)
+	terminationWait.DeploymentInfo.InstanceTerminationWaitTimeStarted = true
+
+	for _, continueTerminationWait := range []bool{false, true} {
+		client := &mockCodeDeployClient{GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+			newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0),
+			terminationWait,
+			terminationWait,
+		}}
+
+		poller := NewDeploymentPoller(client, time.Millisecond, nil)
+		poller.ReturnWhenTrafficShifted = true
+		poller.ContinueTerminationWait = continueTerminationWait
+
+		if err := poller.Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if continueTerminationWait != (len(client.ContinueDeploymentInputs) == 1) {
+			t.Errorf("unexpected ContinueDeployment calls: %+v", client.ContinueDeploymentInputs)
+		}
+		if continueTerminationWait && client.ContinueDeploymentInputs[0].DeploymentWaitType != types.DeploymentWaitTypeTerminationWait {
+			t.Error("unexpected wait type")
+		}
+	}
+}
+
+func TestDeploymentPoller_WithoutHandlers(t *testing.T) {
+	terminationWait := newMockGetDeploymentOutput(types.DeploymentStatusInProgress, 0)
+	terminationWait.DeploymentInfo.InstanceTerminationWaitTimeStarted = true
+
+	client := &mockCodeDeployClient{GetDeploymentOutputs: []*codedeploy.GetDeploymentOutput{
+		newMockGetDeploymentOutput(types.DeploymentStatusReady, 0),
+		terminationWait,
+		newMockGetDeploymentOutput(types.DeploymentStatusSucceeded, 1),
+	}}
+
+	events := 0
+	poller := NewDeploymentPoller(client, time.Millisecond, func(_ DeploymentEvent) { events++ })
+	poller.ReadyHandler = func(_ context.Context, _ DeploymentEvent) error {
+		t.Error("unexpected ReadyHandler call")
+		return nil
+	}
+	poller.ReturnWhenTrafficShifted = true
+	poller.ContinueTerminationWait = true
+
+	if err := poller.WithoutHandlers().Wait(context.Background(), &codedeploy.GetDeploymentInput{DeploymentId: aws.String("mock")}, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if events != 3 {
+		t.Errorf("returned before the deployment succeeded, after %d events", events)
+	}
+	if len(client.ContinueDeploymentInputs) != 0 {
+		t.Errorf("unexpected ContinueDeployment calls: %+v", client.ContinueDeploymentInputs)
+	}
+}
+
+func TestDeploymentPoller_Continue_alreadyCompleted(t *testing.T) {
+	client := &mockCodeDeployClient{ContinueDeploymentErr: &types.DeploymentAlreadyCompletedException{Message: aws.String("mock")}}
+
+	if err := NewDeploymentPoller(client, time.Millisecond, nil).Continue(context.Background(), "mock", types.DeploymentWaitTypeTerminationWait); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
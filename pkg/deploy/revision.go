@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+)
+
+// RevisionSource provides the location of the revision which CreateDeployment deploys.
+type RevisionSource interface {
+	RevisionLocation() *types.RevisionLocation
+}
+
+// AppSpecContentRevision sends the AppSpec along with the deployment, as set by WithAppSpec or WithAppSpecFile.
+type AppSpecContentRevision struct {
+	// Content is the AppSpec in its normalized JSON form, so that equal AppSpecs have equal hashes.
+	Content []byte
+}
+
+func (r AppSpecContentRevision) RevisionLocation() *types.RevisionLocation {
+	return &types.RevisionLocation{
+		AppSpecContent: &types.AppSpecContent{
+			Content: aws.String(string(r.Content)),
+			Sha256:  aws.String(appSpecSha256(r.Content)),
+		},
+		RevisionType: types.RevisionLocationTypeAppSpecContent,
+	}
+}
+
+// S3Revision is a bundle stored in an S3 bucket.
+// Version and ETag are optional.
+type S3Revision struct {
+	Bucket     string
+	Key        string
+	BundleType types.BundleType
+	Version    string
+	ETag       string
+}
+
+func (r S3Revision) RevisionLocation() *types.RevisionLocation {
+	location := &types.S3Location{
+		Bucket:     aws.String(r.Bucket),
+		Key:        aws.String(r.Key),
+		BundleType: r.BundleType,
+	}
+
+	if len(r.Version) > 0 {
+		location.Version = aws.String(r.Version)
+	}
+
+	if len(r.ETag) > 0 {
+		location.ETag = aws.String(r.ETag)
+	}
+
+	return &types.RevisionLocation{S3Location: location, RevisionType: types.RevisionLocationTypeS3}
+}
+
+// GitHubRevision is a commit of a GitHub repository, which has to be connected to the CodeDeploy application.
+type GitHubRevision struct {
+	// Repository has the format "account/repository".
+	Repository string
+	CommitID   string
+}
+
+func (r GitHubRevision) RevisionLocation() *types.RevisionLocation {
+	return &types.RevisionLocation{
+		GitHubLocation: &types.GitHubLocation{
+			Repository: aws.String(r.Repository),
+			CommitId:   aws.String(r.CommitID),
+		},
+		RevisionType: types.RevisionLocationTypeGitHub,
+	}
+}
+
+// RegisterApplicationRevision registers the revision with the application, so that it is listed among its revisions.
+func RegisterApplicationRevision(ctx context.Context, client CodeDeployClient, applicationName string, revision RevisionSource) error {
+	input := &codedeploy.RegisterApplicationRevisionInput{ApplicationName: aws.String(applicationName), Revision: revision.RevisionLocation()}
+
+	if _, err := client.RegisterApplicationRevision(ctx, input); err != nil {
+		return fmt.Errorf("cannot register revision of application %q: %w", applicationName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"testing"
+)
+
+func TestS3Revision_RevisionLocation(t *testing.T) {
+	location := S3Revision{Bucket: "bucket", Key: "app.zip", BundleType: types.BundleTypeZip, ETag: "etag"}.RevisionLocation()
+
+	if location.RevisionType != types.RevisionLocationTypeS3 || *location.S3Location.Bucket != "bucket" || *location.S3Location.Key != "app.zip" || location.S3Location.BundleType != types.BundleTypeZip {
+		t.Errorf("unexpected location: %+v", location.S3Location)
+	}
+
+	if location.S3Location.Version != nil || *location.S3Location.ETag != "etag" {
+		t.Error("unexpected version or ETag")
+	}
+}
+
+func TestGitHubRevision_RevisionLocation(t *testing.T) {
+	location := GitHubRevision{Repository: "joeig/app", CommitID: "abc123"}.RevisionLocation()
+
+	if location.RevisionType != types.RevisionLocationTypeGitHub || *location.GitHubLocation.Repository != "joeig/app" || *location.GitHubLocation.CommitId != "abc123" {
+		t.Errorf("unexpected location: %+v", location.GitHubLocation)
+	}
+}
+
+func TestCodeDeployContext_WithRevision(t *testing.T) {
+	codeDeployContext, _ := NewCodeDeployContext(nil, nil, nil).WithAppSpec(&AppSpec{})
+	codeDeployContext.WithRevision(GitHubRevision{Repository: "joeig/app", CommitID: "abc123"})
+
+	input, err := codeDeployContext.CreateDeploymentInput("a", "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if input.Revision.RevisionType != types.RevisionLocationTypeGitHub {
+		t.Error("unexpected revision")
+	}
+
+	if codeDeployContext.AppSpec() != nil || codeDeployContext.AppSpecSha256() != "" {
+		t.Error("AppSpec not reset")
+	}
+}
+
+func TestRegisterApplicationRevision(t *testing.T) {
+	client := &mockCodeDeployClient{}
+
+	if err := RegisterApplicationRevision(context.Background(), client, "app", S3Revision{Bucket: "bucket", Key: "app.zip", BundleType: types.BundleTypeZip}); err != nil {
+		t.Fatal(err)
+	}
+
+	if *client.RegisterApplicationRevisionInput.ApplicationName != "app" || client.RegisterApplicationRevisionInput.Revision.RevisionType != types.RevisionLocationTypeS3 {
+		t.Errorf("unexpected input: %+v", client.RegisterApplicationRevisionInput)
+	}
+}
+
+func TestRegisterApplicationRevision_error(t *testing.T) {
+	client := &mockCodeDeployClient{RegisterApplicationRevisionErr: errors.New("mock")}
+
+	if err := RegisterApplicationRevision(context.Background(), client, "app", GitHubRevision{Repository: "joeig/app", CommitID: "abc123"}); err == nil {
+		t.Error("no error")
+	}
+}
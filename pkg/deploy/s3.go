@@ -0,0 +1,32 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"strings"
+)
+
+type S3Client interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// UploadBundle uploads a revision bundle and returns it as S3Revision.
+// The revision refers to the uploaded object version and ETag, so that later uploads to the same key don't change it.
+func UploadBundle(ctx context.Context, client S3Client, body io.ReadSeeker, bucket, key string, bundleType types.BundleType) (S3Revision, error) {
+	output, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: body})
+	if err != nil {
+		return S3Revision{}, fmt.Errorf("cannot upload bundle to bucket %q: %w", bucket, err)
+	}
+
+	return S3Revision{
+		Bucket:     bucket,
+		Key:        key,
+		BundleType: bundleType,
+		Version:    aws.ToString(output.VersionId),
+		ETag:       strings.Trim(aws.ToString(output.ETag), `"`),
+	}, nil
+}
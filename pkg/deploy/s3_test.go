@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"testing"
+)
+
+type mockS3Client struct {
+	PutObjectInput  *s3.PutObjectInput
+	PutObjectOutput *s3.PutObjectOutput
+	PutObjectErr    error
+}
+
+func (m *mockS3Client) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	m.PutObjectInput = params
+	return m.PutObjectOutput, m.PutObjectErr
+}
+
+func TestUploadBundle(t *testing.T) {
+	client := &mockS3Client{PutObjectOutput: &s3.PutObjectOutput{ETag: aws.String(`"etag"`), VersionId: aws.String("version")}}
+
+	revision, err := UploadBundle(context.Background(), client, bytes.NewReader([]byte("bundle")), "bucket", "app.zip", types.BundleTypeZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := S3Revision{Bucket: "bucket", Key: "app.zip", BundleType: types.BundleTypeZip, Version: "version", ETag: "etag"}
+	if revision != want {
+		t.Errorf("unexpected revision: %+v", revision)
+	}
+
+	if *client.PutObjectInput.Bucket != "bucket" || *client.PutObjectInput.Key != "app.zip" {
+		t.Error("unexpected input")
+	}
+}
+
+func TestUploadBundle_error(t *testing.T) {
+	client := &mockS3Client{PutObjectErr: errors.New("mock")}
+
+	if _, err := UploadBundle(context.Background(), client, bytes.NewReader(nil), "bucket", "app.zip", types.BundleTypeZip); err == nil {
+		t.Error("no error")
+	}
+}
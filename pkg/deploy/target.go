@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+)
+
+// batchGetDeploymentTargetsLimit is the maximum number of target IDs per BatchGetDeploymentTargets request.
+const batchGetDeploymentTargetsLimit = 25
+
+// DeploymentTarget is the common part of ECS, Lambda, instance and CloudFormation deployment targets.
+type DeploymentTarget struct {
+	TargetID        string
+	Type            types.DeploymentTargetType
+	Status          types.TargetStatus
+	LifecycleEvents []types.LifecycleEvent
+}
+
+// FailedLifecycleEvent returns the first failed lifecycle event of the target, or nil.
+func (t DeploymentTarget) FailedLifecycleEvent() *types.LifecycleEvent {
+	for i := range t.LifecycleEvents {
+		if t.LifecycleEvents[i].Status == types.LifecycleEventStatusFailed {
+			return &t.LifecycleEvents[i]
+		}
+	}
+	return nil
+}
+
+func newDeploymentTarget(target types.DeploymentTarget) DeploymentTarget {
+	deploymentTarget := DeploymentTarget{Type: target.DeploymentTargetType}
+
+	switch {
+	case target.EcsTarget != nil:
+		deploymentTarget.TargetID = aws.ToString(target.EcsTarget.TargetId)
+		deploymentTarget.Status = target.EcsTarget.Status
+		deploymentTarget.LifecycleEvents = target.EcsTarget.LifecycleEvents
+	case target.LambdaTarget != nil:
+		deploymentTarget.TargetID = aws.ToString(target.LambdaTarget.TargetId)
+		deploymentTarget.Status = target.LambdaTarget.Status
+		deploymentTarget.LifecycleEvents = target.LambdaTarget.LifecycleEvents
+	case target.InstanceTarget != nil:
+		deploymentTarget.TargetID = aws.ToString(target.InstanceTarget.TargetId)
+		deploymentTarget.Status = target.InstanceTarget.Status
+		deploymentTarget.LifecycleEvents = target.InstanceTarget.LifecycleEvents
+	case target.CloudFormationTarget != nil:
+		deploymentTarget.TargetID = aws.ToString(target.CloudFormationTarget.TargetId)
+		deploymentTarget.Status = target.CloudFormationTarget.Status
+		deploymentTarget.LifecycleEvents = target.CloudFormationTarget.LifecycleEvents
+	}
+
+	return deploymentTarget
+}
+
+// GetDeploymentTargets returns all targets of a deployment including their lifecycle events.
+func GetDeploymentTargets(ctx context.Context, client CodeDeployClient, deploymentID string) ([]DeploymentTarget, error) {
+	var targetIDs []string
+
+	listInput := &codedeploy.ListDeploymentTargetsInput{DeploymentId: aws.String(deploymentID)}
+	for {
+		output, err := client.ListDeploymentTargets(ctx, listInput)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list deployment targets: %w", err)
+		}
+
+		targetIDs = append(targetIDs, output.TargetIds...)
+
+		if output.NextToken == nil {
+			break
+		}
+		listInput.NextToken = output.NextToken
+	}
+
+	targets := make([]DeploymentTarget, 0, len(targetIDs))
+
+	for start := 0; start < len(targetIDs); start += batchGetDeploymentTargetsLimit {
+		end := min(start+batchGetDeploymentTargetsLimit, len(targetIDs))
+
+		output, err := client.BatchGetDeploymentTargets(ctx, &codedeploy.BatchGetDeploymentTargetsInput{
+			DeploymentId: aws.String(deploymentID),
+			TargetIds:    targetIDs[start:end],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot get deployment targets: %w", err)
+		}
+
+		for _, target := range output.DeploymentTargets {
+			targets = append(targets, newDeploymentTarget(target))
+		}
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,85 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"testing"
+)
+
+func TestGetDeploymentTargets(t *testing.T) {
+	var targetIDs []string
+	for i := 0; i < 30; i++ {
+		targetIDs = append(targetIDs, fmt.Sprintf("target-%d", i))
+	}
+
+	client := &mockCodeDeployClient{
+		ListDeploymentTargetsOutputs: []*codedeploy.ListDeploymentTargetsOutput{
+			{TargetIds: targetIDs[:20], NextToken: aws.String("1")},
+			{TargetIds: targetIDs[20:]},
+		},
+		BatchGetDeploymentTargetsOutput: &codedeploy.BatchGetDeploymentTargetsOutput{
+			DeploymentTargets: []types.DeploymentTarget{
+				{
+					DeploymentTargetType: types.DeploymentTargetTypeEcsTarget,
+					EcsTarget: &types.ECSTarget{
+						TargetId: aws.String("cluster:service"),
+						Status:   types.TargetStatusFailed,
+						LifecycleEvents: []types.LifecycleEvent{
+							{LifecycleEventName: aws.String("BeforeInstall"), Status: types.LifecycleEventStatusSucceeded},
+							{LifecycleEventName: aws.String("AfterAllowTestTraffic"), Status: types.LifecycleEventStatusFailed},
+						},
+					},
+				},
+				{
+					DeploymentTargetType: types.DeploymentTargetTypeLambdaTarget,
+					LambdaTarget: &types.LambdaTarget{
+						TargetId: aws.String("function:alias"),
+						Status:   types.TargetStatusSucceeded,
+					},
+				},
+			},
+		},
+	}
+
+	targets, err := GetDeploymentTargets(context.Background(), client, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.BatchGetDeploymentTargetsInputs) != 2 || len(client.BatchGetDeploymentTargetsInputs[0].TargetIds) != 25 || len(client.BatchGetDeploymentTargetsInputs[1].TargetIds) != 5 {
+		t.Error("unexpected batches")
+	}
+
+	if len(targets) != 4 || targets[0].TargetID != "cluster:service" || targets[0].Status != types.TargetStatusFailed || targets[1].Type != types.DeploymentTargetTypeLambdaTarget {
+		t.Errorf("unexpected targets: %+v", targets)
+	}
+
+	if event := targets[0].FailedLifecycleEvent(); event == nil || *event.LifecycleEventName != "AfterAllowTestTraffic" {
+		t.Error("unexpected failed lifecycle event")
+	}
+
+	if targets[1].FailedLifecycleEvent() != nil {
+		t.Error("unexpected failed lifecycle event")
+	}
+}
+
+func TestGetDeploymentTargets_error(t *testing.T) {
+	client := &mockCodeDeployClient{ListDeploymentTargetsErr: errors.New("mock")}
+
+	if _, err := GetDeploymentTargets(context.Background(), client, "mock"); err == nil {
+		t.Error("no error")
+	}
+
+	client = &mockCodeDeployClient{
+		ListDeploymentTargetsOutputs: []*codedeploy.ListDeploymentTargetsOutput{{TargetIds: []string{"target"}}},
+		BatchGetDeploymentTargetsErr: errors.New("mock"),
+	}
+
+	if _, err := GetDeploymentTargets(context.Background(), client, "mock"); err == nil {
+		t.Error("no error")
+	}
+}
@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	taskDefinitionARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:ecs:[a-z0-9-]+:\d{12}:task-definition/[a-zA-Z0-9_-]{1,255}:\d+$`)
+	functionNamePattern      = regexp.MustCompile(`^(arn:aws[a-z-]*:lambda:[a-z0-9-]+:\d{12}:function:)?[a-zA-Z0-9_-]{1,64}$`)
+	hookFunctionNamePattern  = regexp.MustCompile(`^(arn:aws[a-z-]*:lambda:[a-z0-9-]+:\d{12}:function:)?[a-zA-Z0-9_-]{1,64}(:(\$LATEST|[a-zA-Z0-9_-]+))?$`)
+	functionVersionPattern   = regexp.MustCompile(`^\d+$`)
+)
+
+type problems []error
+
+func (p *problems) add(field string, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+// Validate checks the AppSpec for problems which would otherwise only be reported by CodeDeploy.
+// All problems are joined into the returned error.
+func (a *AppSpec) Validate() error {
+	var p problems
+
+	if a.Version != DefaultVersion {
+		p.add("version", "must be %q", DefaultVersion)
+	}
+
+	if len(a.Resources) != 1 {
+		p.add("Resources", "must contain exactly one resource, got %d", len(a.Resources))
+	}
+
+	for i, resource := range a.Resources {
+		field := fmt.Sprintf("Resources[%d]", i)
+
+		switch properties := resource.TargetService.Properties.(type) {
+		case ECSProperties:
+			p.validateECSProperties(field+".Properties", properties)
+		case LambdaProperties:
+			p.validateLambdaProperties(field+".Properties", properties)
+		default:
+			p.add(field+".Type", "unknown target service type %q", resource.TargetService.Type)
+		}
+	}
+
+	// Hooks are checked against the first resource only, since AppSpecs with more resources are reported above.
+	var targetServiceType TargetServiceType
+	if len(a.Resources) > 0 {
+		targetServiceType = a.Resources[0].TargetService.Type
+	}
+
+	for i, hook := range a.Hooks {
+		if _, known := hookNames[targetServiceType]; known {
+			if err := checkHookName(targetServiceType, hook.Name); err != nil {
+				p.add(fmt.Sprintf("Hooks[%d]", i), "%s", err)
+			}
+		}
+
+		if !hookFunctionNamePattern.MatchString(hook.FunctionName) {
+			p.add(fmt.Sprintf("Hooks[%d].%s", i, hook.Name), "must be a Lambda function name or ARN, got %q", hook.FunctionName)
+		}
+	}
+
+	return errors.Join(p...)
+}
+
+func (p *problems) validateECSProperties(field string, properties ECSProperties) {
+	if !taskDefinitionARNPattern.MatchString(properties.TaskDefinition) {
+		p.add(field+".TaskDefinition", "must be a task definition ARN, got %q", properties.TaskDefinition)
+	}
+
+	if len(properties.LoadBalancerInfo.ContainerName) == 0 {
+		p.add(field+".LoadBalancerInfo.ContainerName", "must not be empty")
+	}
+
+	if properties.LoadBalancerInfo.ContainerPort < 1 || properties.LoadBalancerInfo.ContainerPort > 65535 {
+		p.add(field+".LoadBalancerInfo.ContainerPort", "contains an invalid port number %d", properties.LoadBalancerInfo.ContainerPort)
+	}
+
+	if properties.NetworkConfiguration != nil {
+		awsvpcConfiguration := properties.NetworkConfiguration.AwsvpcConfiguration
+
+		if len(awsvpcConfiguration.Subnets) == 0 {
+			p.add(field+".NetworkConfiguration.AwsvpcConfiguration.Subnets", "must not be empty")
+		}
+
+		switch awsvpcConfiguration.AssignPublicIP {
+		case "", AssignPublicIPEnabled, AssignPublicIPDisabled:
+		default:
+			p.add(field+".NetworkConfiguration.AwsvpcConfiguration.AssignPublicIp", "must be either %q or %q", AssignPublicIPEnabled, AssignPublicIPDisabled)
+		}
+	}
+
+	for i, item := range properties.CapacityProviderStrategy {
+		itemField := fmt.Sprintf("%s.CapacityProviderStrategy[%d]", field, i)
+
+		if len(item.CapacityProvider) == 0 {
+			p.add(itemField+".CapacityProvider", "must not be empty")
+		}
+
+		if item.Weight < 0 || item.Weight > CapacityProviderMaxWeight {
+			p.add(itemField+".Weight", "must be between 0 and %d", CapacityProviderMaxWeight)
+		}
+
+		if item.Base < 0 || item.Base > CapacityProviderMaxBase {
+			p.add(itemField+".Base", "must be between 0 and %d", CapacityProviderMaxBase)
+		}
+	}
+}
+
+func (p *problems) validateLambdaProperties(field string, properties LambdaProperties) {
+	if !functionNamePattern.MatchString(properties.Name) {
+		p.add(field+".Name", "must be a Lambda function name or ARN, got %q", properties.Name)
+	}
+
+	if len(properties.Alias) == 0 {
+		p.add(field+".Alias", "must not be empty")
+	}
+
+	if !functionVersionPattern.MatchString(properties.CurrentVersion) {
+		p.add(field+".CurrentVersion", "must be a published function version, got %q", properties.CurrentVersion)
+	}
+
+	if !functionVersionPattern.MatchString(properties.TargetVersion) {
+		p.add(field+".TargetVersion", "must be a published function version, got %q", properties.TargetVersion)
+	}
+
+	if properties.CurrentVersion == properties.TargetVersion {
+		p.add(field+".TargetVersion", "must differ from the current version")
+	}
+}
@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAppSpec_Validate(t *testing.T) {
+	ecsAppSpec := NewECS("arn:aws:ecs:eu-central-1:123456789012:task-definition/app:42", "app", 1337, WithAwsvpcConfiguration([]string{"subnet-1"}, nil, AssignPublicIPDisabled))
+	if err := ecsAppSpec.AddHook(AfterAllowTestTrafficHook, "arn:aws:lambda:eu-central-1:123456789012:function:validate:live"); err != nil {
+		t.Fatal(err)
+	}
+
+	lambdaAppSpec := NewLambda("function-name", "live", "42", "43")
+	if err := lambdaAppSpec.AddHook(BeforeAllowTrafficHook, "validate"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, appSpec := range []*AppSpec{ecsAppSpec, lambdaAppSpec} {
+		if err := appSpec.Validate(); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestAppSpec_Validate_problems(t *testing.T) {
+	tests := []struct {
+		name         string
+		appSpec      *AppSpec
+		wantProblems int
+	}{
+		{
+			name:         "empty",
+			appSpec:      &AppSpec{},
+			wantProblems: 2,
+		},
+		{
+			name:         "ECS",
+			appSpec:      NewECS("my-task-def", "", 0, WithAwsvpcConfiguration(nil, nil, "yes")),
+			wantProblems: 5,
+		},
+		{
+			name:         "Lambda",
+			appSpec:      NewLambda("function name", "", "$LATEST", "$LATEST"),
+			wantProblems: 5,
+		},
+		{
+			name: "hooks",
+			appSpec: &AppSpec{
+				Version:   DefaultVersion,
+				Resources: NewLambda("function-name", "live", "42", "43").Resources,
+				Hooks:     []Hook{{Name: BeforeInstallHook, FunctionName: "arn:aws:s3:::bucket"}},
+			},
+			wantProblems: 2,
+		},
+		{
+			name: "hooks of several resources",
+			appSpec: &AppSpec{
+				Version:   DefaultVersion,
+				Resources: append(NewLambda("function-name", "live", "42", "43").Resources, NewLambda("other-function-name", "live", "42", "43").Resources...),
+				Hooks:     []Hook{{Name: BeforeInstallHook, FunctionName: "validate"}},
+			},
+			wantProblems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.appSpec.Validate()
+
+			var joinedErr interface{ Unwrap() []error }
+			if !errors.As(err, &joinedErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if problems := joinedErr.Unwrap(); len(problems) != tt.wantProblems {
+				t.Errorf("got %d problems, want %d: %v", len(problems), tt.wantProblems, problems)
+			}
+		})
+	}
+}